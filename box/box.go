@@ -2,50 +2,80 @@ package box
 
 import (
 	"fmt"
+	"math"
 	"sort"
 	"strings"
+
+	"github.com/vegarsti/extract/direction"
+	"github.com/vegarsti/extract/geometry"
 )
 
 // Box is a data structure representing a box in an image,
 // with x and y float coordinates, and the text inside the box.
+// Page is the page of the document the box is on, starting at 1.
+// Selected is set for selection elements such as checkboxes, and is true if it's ticked;
+// the content of a selection element is then "true" or "false".
+// Confidence is how sure the OCR is of the content, from 0 to 100, or 0 if unknown.
 type Box struct {
-	XLeft   float64
-	XRight  float64
-	YBottom float64
-	YTop    float64
-	Content string
+	XLeft      float64
+	XRight     float64
+	YBottom    float64
+	YTop       float64
+	Content    string
+	Page       int
+	Selected   *bool   `json:",omitempty"`
+	Confidence float64 `json:",omitempty"`
+}
+
+// FromRect returns a box without content covering the rectangle
+func FromRect(r geometry.Rect) Box {
+	return Box{XLeft: r.Left, XRight: r.Right, YTop: r.Top, YBottom: r.Bottom}
+}
+
+// Rect is the rectangle covered by the box
+func (b Box) Rect() geometry.Rect {
+	return geometry.Rect{Left: b.XLeft, Right: b.XRight, Top: b.YTop, Bottom: b.YBottom}
+}
+
+// Pages returns the sorted page numbers of the boxes
+func Pages(boxes []Box) []int {
+	seen := make(map[int]bool)
+	pages := make([]int, 0)
+	for _, b := range boxes {
+		if !seen[b.Page] {
+			seen[b.Page] = true
+			pages = append(pages, b.Page)
+		}
+	}
+	sort.Ints(pages)
+	return pages
+}
+
+// OnPage returns the boxes on the given page
+func OnPage(boxes []Box, page int) []Box {
+	onPage := make([]Box, 0)
+	for _, b := range boxes {
+		if b.Page == page {
+			onPage = append(onPage, b)
+		}
+	}
+	return onPage
 }
 
 // Inside other box o if it is completely inside,
 // i.e. all coordinates for the outer box are more extreme or overlap
 func (b Box) Inside(o Box) bool {
-	return o.XLeft <= b.XLeft && o.XRight >= b.XRight && o.YTop <= b.YTop && o.YBottom >= b.YBottom
+	return o.Rect().ContainsRect(b.Rect(), 0)
 }
 
 // Box's x coordinates overlap with the region of left and right
 func (b Box) XOverlap(left float64, right float64) bool {
-	// box is to the left
-	if b.XRight < left {
-		return false
-	}
-	// box is to the right
-	if b.XLeft > right {
-		return false
-	}
-	return true
+	return b.Rect().XOverlap(geometry.Rect{Left: left, Right: right})
 }
 
 // Box's y coordinates overlap with the region of top and bottom
 func (b Box) YOverlap(top float64, bottom float64) bool {
-	// box is above
-	if b.YBottom < top {
-		return false
-	}
-	// box is below
-	if b.YTop > bottom {
-		return false
-	}
-	return true
+	return b.Rect().YOverlap(geometry.Rect{Top: top, Bottom: bottom})
 }
 
 // Find all non-overlapping regions in x direction of coordinates
@@ -99,6 +129,38 @@ func YRegions(boxes []Box) [][]float64 {
 	return mergeRegions(regions)
 }
 
+// RowRegions finds the regions in y direction of the rows of boxes,
+// by clustering the boxes on their vertical center with geometry.ClusterRows.
+// Unlike YRegions, rows that overlap a little, e.g. because the image is slanted, are kept apart.
+// The regions don't overlap: rows that do are split in the middle of the overlap.
+func RowRegions(boxes []Box) [][]float64 {
+	clusters := geometry.ClusterRows(
+		len(boxes),
+		func(i int) float64 { return boxes[i].YTop },
+		func(i int) float64 { return boxes[i].YBottom },
+	)
+	regions := make([][]float64, len(clusters))
+	for i, cluster := range clusters {
+		regions[i] = []float64{boxes[cluster[0]].YTop, boxes[cluster[0]].YBottom}
+		for _, k := range cluster[1:] {
+			regions[i][0] = min(regions[i][0], boxes[k].YTop)
+			regions[i][1] = max(regions[i][1], boxes[k].YBottom)
+		}
+	}
+	// the rows are ordered by their center, from top to bottom
+	for i := 0; i+1 < len(regions); i++ {
+		above, below := regions[i], regions[i+1]
+		if above[1] <= below[0] {
+			continue
+		}
+		middle := (above[1] + below[0]) / 2
+		above[1] = max(middle, above[0])
+		below[0] = above[1]
+		below[1] = max(below[1], below[0])
+	}
+	return regions
+}
+
 // Remove duplicates
 func mergeRegions(regions [][]float64) [][]float64 {
 	newRegions := make([][]float64, 0)
@@ -161,12 +223,7 @@ func CartesianProduct(xRegions [][]float64, yRegions [][]float64) [][]Box {
 	for i, yRegion := range yRegions {
 		rows[i] = make([]Box, len(xRegions))
 		for j, xRegion := range xRegions {
-			rows[i][j] = Box{
-				XLeft:   xRegion[0],
-				XRight:  xRegion[1],
-				YBottom: yRegion[1],
-				YTop:    yRegion[0],
-			}
+			rows[i][j] = FromRect(geometry.Rect{Left: xRegion[0], Right: xRegion[1], Top: yRegion[0], Bottom: yRegion[1]})
 		}
 	}
 	return rows
@@ -193,9 +250,8 @@ func (c RowsOfBoxes) Less(i, j int) bool {
 	if c[i][0].YTop > c[j][0].YBottom {
 		return false // i should be first
 	}
-	// same row, so compare x
-	fmt.Println("This won't happen right?")
-	return c[0][i].XLeft < c[0][j].XLeft
+	// rows that overlap, e.g. in slanted images, are ordered by their vertical center
+	return c[i][0].YTop+c[i][0].YBottom < c[j][0].YTop+c[j][0].YBottom
 }
 
 // Boxes
@@ -226,23 +282,181 @@ func (c Boxes) Less(i, j int) bool {
 	return c[i].XLeft < c[j].XLeft
 }
 
+// Assign puts each box in exactly one cell: the first cell that covers the box horizontally
+// and its center vertically, so a box that reaches into the row below isn't put in both rows
 func Assign(rows [][]Box, boxes []Box) {
-	// Fill cell with corresponding boxes
-	// Find word boxes to put in this cell
-	// Assign table cell (x, y) to each box:
-	// find all
+	inside := make(map[[2]int][]Box)
+	for _, b := range boxes {
+		center := b.Rect().Center()
+		found := false
+		for i := range rows {
+			for j, cell := range rows[i] {
+				if b.XLeft >= cell.XLeft && b.XRight <= cell.XRight && center.Y >= cell.YTop && center.Y <= cell.YBottom {
+					inside[[2]int{i, j}] = append(inside[[2]int{i, j}], b)
+					found = true
+					break
+				}
+			}
+			if found {
+				break
+			}
+		}
+	}
 	for i := range rows {
 		for j := range rows[i] {
-			c := Boxes(boxes)
-			sort.Sort(c)
-			boxes = []Box(c)
-			for _, b := range boxes {
-				if b.Inside(rows[i][j]) {
-					rows[i][j].Content = strings.Trim(rows[i][j].Content+" "+b.Content, " ")
+			fill(&rows[i][j], inside[[2]int{i, j}])
+		}
+	}
+}
+
+// fill the cell with the content of the boxes
+func fill(cell *Box, boxes []Box) {
+	if len(boxes) == 0 {
+		return
+	}
+	cell.Content = strings.Trim(cell.Content+" "+Text(boxes), " ")
+	for _, b := range boxes {
+		if b.Selected != nil {
+			cell.Selected = b.Selected
+		}
+		// the cell is no more certain than its least certain word
+		if b.Confidence > 0 && (cell.Confidence == 0 || b.Confidence < cell.Confidence) {
+			cell.Confidence = b.Confidence
+		}
+	}
+}
+
+// Text joins the content of the boxes in reading order: line by line, and within a line
+// from left to right, or from right to left if the text is in a right-to-left script
+func Text(boxes []Box) string {
+	c := make(Boxes, len(boxes))
+	copy(c, boxes)
+	sort.Sort(c)
+	contents := make([]string, len(c))
+	for i, b := range c {
+		contents[i] = b.Content
+	}
+	if direction.IsRTL(strings.Join(contents, "")) {
+		// reverse the boxes on each line
+		start := 0
+		for i := 1; i <= len(c); i++ {
+			if i < len(c) && c[i].YTop <= c[i-1].YBottom {
+				continue
+			}
+			for l, r := start, i-1; l < r; l, r = l+1, r-1 {
+				contents[l], contents[r] = contents[r], contents[l]
+			}
+			start = i
+		}
+	}
+	return strings.Trim(strings.Join(contents, " "), " ")
+}
+
+// IsRTL is true if most of the cells with text are in a right-to-left script
+func IsRTL(rows [][]Box) bool {
+	rtl := 0
+	ltr := 0
+	for _, row := range rows {
+		for _, cell := range row {
+			if cell.Content == "" {
+				continue
+			}
+			if direction.IsRTL(cell.Content) {
+				rtl++
+			} else {
+				ltr++
+			}
+		}
+	}
+	return rtl > ltr
+}
+
+// ReverseColumns returns the rows with the columns in reverse order,
+// i.e. from right to left
+func ReverseColumns(rows [][]Box) [][]Box {
+	reversed := make([][]Box, len(rows))
+	for i, row := range rows {
+		reversed[i] = make([]Box, len(row))
+		for j, cell := range row {
+			reversed[i][len(row)-1-j] = cell
+		}
+	}
+	return reversed
+}
+
+// MaxHeaderRows is the number of rows at the top of a table
+// that may contain header words spanning several columns
+const MaxHeaderRows = 3
+
+// SplitSpanning splits boxes into regular boxes and header boxes that span
+// several of the x regions of the table body, or are between two of them
+func SplitSpanning(boxes []Box, yRegions [][]float64) ([]Box, []Box) {
+	// we need a table body to compare with
+	if len(yRegions) <= MaxHeaderRows {
+		return boxes, nil
+	}
+	headerBottom := yRegions[MaxHeaderRows-1][1]
+	header := make([]Box, 0)
+	body := make([]Box, 0)
+	for _, b := range boxes {
+		if b.YBottom <= headerBottom {
+			header = append(header, b)
+		} else {
+			body = append(body, b)
+		}
+	}
+	bodyRegions := XRegions(body)
+	regular := body
+	spanning := make([]Box, 0)
+	for _, b := range header {
+		overlaps := 0
+		left := false
+		right := false
+		for _, region := range bodyRegions {
+			if b.XOverlap(region[0], region[1]) {
+				overlaps++
+			}
+			left = left || region[1] < b.XLeft
+			right = right || region[0] > b.XRight
+		}
+		// spans several columns, or is in the gutter between two columns
+		if overlaps > 1 || (overlaps == 0 && left && right) {
+			spanning = append(spanning, b)
+		} else {
+			regular = append(regular, b)
+		}
+	}
+	return regular, spanning
+}
+
+// AssignNearest assigns each box to the cell in its row whose x region
+// contains the center of the box, or else the nearest one.
+// This is used for boxes that are not inside any cell.
+func AssignNearest(rows [][]Box, boxes []Box) {
+	nearestBoxes := make(map[[2]int][]Box)
+	for _, b := range boxes {
+		center := b.Rect().Center()
+		for i := range rows {
+			if len(rows[i]) == 0 || !rows[i][0].YOverlap(center.Y, center.Y) {
+				continue
+			}
+			nearest := 0
+			nearestDistance := math.Inf(1)
+			for j, cell := range rows[i] {
+				distance := cell.Rect().Distance(center.Rect())
+				if distance < nearestDistance {
+					nearest = j
+					nearestDistance = distance
 				}
 			}
+			cell := [2]int{i, nearest}
+			nearestBoxes[cell] = append(nearestBoxes[cell], b)
+			break
 		}
 	}
+	for cell, cellBoxes := range nearestBoxes {
+		fill(&rows[cell[0]][cell[1]], cellBoxes)
+	}
 }
 
 // Returns boxes slice and slice of strings.
@@ -250,16 +464,21 @@ func Assign(rows [][]Box, boxes []Box) {
 func ToTable(boxes []Box) ([][]Box, [][]string) {
 	// TODO: Explain this better
 	// Find all regions in x direction with a box,
-	// and same in y direction
-	xRegions := XRegions(boxes)
-	yRegions := YRegions(boxes)
+	// and the rows in y direction
+	yRegions := RowRegions(boxes)
+	// Header words centered above a group of columns (e.g. "2020" above
+	// "Revenue" and "Cost") would bridge the gutter between the columns
+	// and merge them, so they don't take part in finding the x regions
+	regular, spanning := SplitSpanning(boxes, yRegions)
+	xRegions := XRegions(regular)
 
 	// Create all cells by taking the cartesian product
 	// of x regions and y regions: for each x region, all y regions.
 	rows := CartesianProduct(xRegions, yRegions)
 	// Assign table cell (x, y) to each box
 	// (mutates rows)
-	Assign(rows, boxes)
+	Assign(rows, regular)
+	AssignNearest(rows, spanning)
 
 	// Sort
 	toSort := RowsOfBoxes(rows)
@@ -278,3 +497,232 @@ func ToTable(boxes []Box) ([][]Box, [][]string) {
 	// fmt.Println(rows)
 	return rows, lines
 }
+
+// Prune removes rows and columns that are empty, and merges sparse columns, which have
+// content in at most one row, e.g. a page number or a stray dot, into a neighbouring
+// column that is empty in the same rows. It returns the pruned rows, the indices of
+// the rows that were kept, and a description of each decision for debugging.
+func Prune(rows [][]Box) ([][]Box, []int, []string) {
+	decisions := make([]string, 0)
+	pruned := make([][]Box, 0)
+	kept := make([]int, 0)
+	for i, row := range rows {
+		if filled(row) == 0 {
+			decisions = append(decisions, fmt.Sprintf("removed empty row %d", i))
+			continue
+		}
+		pruned = append(pruned, append([]Box{}, row...))
+		kept = append(kept, i)
+	}
+	if len(pruned) == 0 {
+		return pruned, kept, decisions
+	}
+
+	// columns are identified by their index in the original rows
+	columns := make([]int, len(pruned[0]))
+	for j := range columns {
+		columns[j] = j
+	}
+	for j := len(columns) - 1; j >= 0; j-- {
+		if columnFilled(pruned, j) == 0 {
+			decisions = append(decisions, fmt.Sprintf("removed empty column %d", columns[j]))
+			pruned = removeColumn(pruned, j)
+			columns = append(columns[:j], columns[j+1:]...)
+		}
+	}
+
+	for j := 0; j < len(columns); j++ {
+		if len(columns) == 1 || columnFilled(pruned, j) > 1 {
+			continue
+		}
+		// merge into the nearest neighbour that has no content where this column has
+		neighbour := -1
+		nearest := math.Inf(1)
+		for _, k := range []int{j - 1, j + 1} {
+			if k < 0 || k >= len(columns) || collides(pruned, j, k) {
+				continue
+			}
+			distance := math.Max(pruned[0][k].XLeft-pruned[0][j].XRight, pruned[0][j].XLeft-pruned[0][k].XRight)
+			if distance < nearest {
+				neighbour = k
+				nearest = distance
+			}
+		}
+		if neighbour == -1 {
+			continue
+		}
+		decisions = append(decisions, fmt.Sprintf("merged sparse column %d into column %d", columns[j], columns[neighbour]))
+		for i := range pruned {
+			merged := &pruned[i][neighbour]
+			cell := pruned[i][j]
+			merged.XLeft = min(merged.XLeft, cell.XLeft)
+			merged.XRight = max(merged.XRight, cell.XRight)
+			if cell.Content != "" {
+				merged.Content = cell.Content
+				merged.Selected = cell.Selected
+				merged.Confidence = cell.Confidence
+			}
+		}
+		pruned = removeColumn(pruned, j)
+		columns = append(columns[:j], columns[j+1:]...)
+		j--
+	}
+	return pruned, kept, decisions
+}
+
+func filled(row []Box) int {
+	n := 0
+	for _, cell := range row {
+		if cell.Content != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func columnFilled(rows [][]Box, j int) int {
+	n := 0
+	for _, row := range rows {
+		if row[j].Content != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// collides is true if both columns have content in the same row
+func collides(rows [][]Box, j int, k int) bool {
+	for _, row := range rows {
+		if row[j].Content != "" && row[k].Content != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func removeColumn(rows [][]Box, j int) [][]Box {
+	for i := range rows {
+		rows[i] = append(rows[i][:j], rows[i][j+1:]...)
+	}
+	return rows
+}
+
+// MaxSplitGap is the largest gap, in characters, between the text of two columns
+// that MergeSplitColumns merges
+const MaxSplitGap = 2.0
+
+// MergeSplitColumns merges adjacent columns that are a single field split at a word gap
+// that happens to line up across rows, e.g. "First Last" under a "Name" header.
+// Two columns are merged if the header rows name them as one, with text over at most
+// one of them that reaches over both, and the text in the two columns is never further
+// apart than MaxSplitGap characters. words are the word boxes the rows were made from.
+// It returns the merged rows and a description of each merge for debugging.
+func MergeSplitColumns(rows [][]Box, words []Box, headerRows int) ([][]Box, []string) {
+	decisions := make([]string, 0)
+	if headerRows == 0 || headerRows >= len(rows) {
+		return rows, decisions
+	}
+	charWidth := characterWidth(words)
+	if charWidth == 0 {
+		return rows, decisions
+	}
+	for j := 0; j+1 < len(rows[0]); j++ {
+		if !splitField(rows, words, headerRows, j, charWidth) {
+			continue
+		}
+		decisions = append(decisions, fmt.Sprintf("merged column %d into column %d", j+1, j))
+		for i := range rows {
+			left := &rows[i][j]
+			right := rows[i][j+1]
+			left.XRight = right.XRight
+			contents := []string{left.Content, right.Content}
+			if direction.IsRTL(left.Content + right.Content) {
+				contents[0], contents[1] = contents[1], contents[0]
+			}
+			left.Content = strings.TrimSpace(strings.Join(contents, " "))
+			if left.Selected == nil {
+				left.Selected = right.Selected
+			}
+			if right.Confidence > 0 && (left.Confidence == 0 || right.Confidence < left.Confidence) {
+				left.Confidence = right.Confidence
+			}
+		}
+		rows = removeColumn(rows, j+1)
+		j--
+	}
+	return rows, decisions
+}
+
+// splitField is true if column j and the next are a single field
+func splitField(rows [][]Box, words []Box, headerRows int, j int, charWidth float64) bool {
+	spans := false
+	for _, row := range rows[:headerRows] {
+		if row[j].Content != "" && row[j+1].Content != "" {
+			return false
+		}
+		for _, cell := range row[j : j+2] {
+			if cell.Content == "" {
+				continue
+			}
+			// a header over only one of the columns, e.g. "Name" next to an unlabeled column of codes,
+			// doesn't make them one field
+			left, right, ok := textExtent(cell, words)
+			if ok && left < row[j].XRight && right > row[j+1].XLeft {
+				spans = true
+			}
+		}
+	}
+	if !spans {
+		return false
+	}
+	both := 0
+	for _, row := range rows[headerRows:] {
+		if row[j].Content == "" || row[j+1].Content == "" {
+			continue
+		}
+		both++
+		_, right, ok := textExtent(row[j], words)
+		if !ok {
+			return false
+		}
+		left, _, ok := textExtent(row[j+1], words)
+		if !ok || left-right > MaxSplitGap*charWidth {
+			return false
+		}
+	}
+	return both > 0
+}
+
+// textExtent returns the left and right edge of the words with their center in the cell
+func textExtent(cell Box, words []Box) (float64, float64, bool) {
+	var extent *geometry.Rect
+	for _, w := range words {
+		if w.Page != cell.Page || !cell.Rect().Contains(w.Rect().Center(), 0) {
+			continue
+		}
+		r := w.Rect()
+		if extent != nil {
+			r = extent.Union(r)
+		}
+		extent = &r
+	}
+	if extent == nil {
+		return 0, 0, false
+	}
+	return extent.Left, extent.Right, true
+}
+
+// characterWidth is the median width of a character in the words
+func characterWidth(words []Box) float64 {
+	widths := make([]float64, 0)
+	for _, w := range words {
+		if n := len([]rune(w.Content)); n > 0 {
+			widths = append(widths, (w.XRight-w.XLeft)/float64(n))
+		}
+	}
+	if len(widths) == 0 {
+		return 0
+	}
+	sort.Float64s(widths)
+	return widths[len(widths)/2]
+}
@@ -0,0 +1,163 @@
+package box
+
+import (
+	"reflect"
+	"testing"
+)
+
+// word returns a box on row i
+func word(text string, i int, left, right float64) Box {
+	top := 0.1 + float64(i)*0.05
+	return Box{Content: text, XLeft: left, XRight: right, YTop: top, YBottom: top + 0.02}
+}
+
+// contents returns the text of the cells
+func contents(rows [][]Box) [][]string {
+	lines := make([][]string, len(rows))
+	for i := range rows {
+		lines[i] = make([]string, len(rows[i]))
+		for j := range rows[i] {
+			lines[i][j] = rows[i][j].Content
+		}
+	}
+	return lines
+}
+
+func TestMergeSplitColumns(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []Box
+		want  [][]string
+	}{
+		{
+			name: "header over both",
+			words: []Box{
+				word("Name", 0, 0.1, 0.27), word("Amount", 0, 0.5, 0.6),
+				word("Ada", 1, 0.1, 0.15), word("Lovelace", 1, 0.17, 0.27), word("100", 1, 0.54, 0.6),
+				word("Alan", 2, 0.1, 0.15), word("Turing", 2, 0.17, 0.25), word("200", 2, 0.54, 0.6),
+				word("Grace", 3, 0.1, 0.15), word("Hopper", 3, 0.17, 0.25), word("300", 3, 0.54, 0.6),
+				word("Edsger", 4, 0.1, 0.15), word("Dijkstra", 4, 0.17, 0.27), word("400", 4, 0.54, 0.6),
+			},
+			want: [][]string{
+				{"Name", "Amount"},
+				{"Ada Lovelace", "100"},
+				{"Alan Turing", "200"},
+				{"Grace Hopper", "300"},
+				{"Edsger Dijkstra", "400"},
+			},
+		},
+		{
+			// the codes are a column of their own, which has no header
+			name: "header over one",
+			words: []Box{
+				word("Name", 0, 0.1, 0.15), word("Amount", 0, 0.5, 0.6),
+				word("Ada", 1, 0.1, 0.15), word("A1", 1, 0.17, 0.21), word("100", 1, 0.54, 0.6),
+				word("Alan", 2, 0.1, 0.15), word("B2", 2, 0.17, 0.21), word("200", 2, 0.54, 0.6),
+				word("Grace", 3, 0.1, 0.15), word("C3", 3, 0.17, 0.21), word("300", 3, 0.54, 0.6),
+				word("Edsger", 4, 0.1, 0.15), word("D4", 4, 0.17, 0.21), word("400", 4, 0.54, 0.6),
+			},
+			want: [][]string{
+				{"Name", "", "Amount"},
+				{"Ada", "A1", "100"},
+				{"Alan", "B2", "200"},
+				{"Grace", "C3", "300"},
+				{"Edsger", "D4", "400"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, _ := ToTable(tt.words)
+			merged, _ := MergeSplitColumns(rows, tt.words, 1)
+			if got := contents(merged); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name  string
+		boxes []Box
+		want  string
+	}{
+		{
+			name:  "left to right",
+			boxes: []Box{word("Cost", 0, 0.3, 0.4), word("Total", 0, 0.1, 0.2)},
+			want:  "Total Cost",
+		},
+		{
+			// the rightmost word is read first
+			name:  "right to left",
+			boxes: []Box{word("חודשי", 0, 0.1, 0.2), word("שכר", 0, 0.3, 0.4)},
+			want:  "שכר חודשי",
+		},
+		{
+			name: "right to left on two lines",
+			boxes: []Box{
+				word("חודשי", 0, 0.1, 0.2), word("שכר", 0, 0.3, 0.4),
+				word("ברוטו", 1, 0.1, 0.2), word("סה״כ", 1, 0.3, 0.4),
+			},
+			want: "שכר חודשי סה״כ ברוטו",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Text(tt.boxes); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReverseColumns(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []Box
+		rtl   bool
+		want  [][]string
+	}{
+		{
+			name: "left to right",
+			words: []Box{
+				word("Item", 0, 0.1, 0.2), word("Amount", 0, 0.5, 0.6),
+				word("Rent", 1, 0.1, 0.2), word("1200", 1, 0.5, 0.6),
+			},
+			rtl: false,
+			want: [][]string{
+				{"Item", "Amount"},
+				{"Rent", "1200"},
+			},
+		},
+		{
+			// the first column is the rightmost one
+			name: "right to left",
+			words: []Box{
+				word("סכום", 0, 0.1, 0.2), word("פריט", 0, 0.5, 0.6),
+				word("1200", 1, 0.1, 0.2), word("שכירות", 1, 0.5, 0.6),
+				word("300", 2, 0.1, 0.2), word("מזון", 2, 0.5, 0.6),
+			},
+			rtl: true,
+			want: [][]string{
+				{"פריט", "סכום"},
+				{"שכירות", "1200"},
+				{"מזון", "300"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, _ := ToTable(tt.words)
+			if got := IsRTL(rows); got != tt.rtl {
+				t.Fatalf("got right to left %t, want %t", got, tt.rtl)
+			}
+			if tt.rtl {
+				rows = ReverseColumns(rows)
+			}
+			if got := contents(rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,31 +3,60 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
-	"text/tabwriter"
 
 	"github.com/vegarsti/extract"
 	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/csv"
+	"github.com/vegarsti/extract/header"
+	"github.com/vegarsti/extract/html"
 	"github.com/vegarsti/extract/image"
+	"github.com/vegarsti/extract/latex"
+	"github.com/vegarsti/extract/markdown"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/plaintext"
+	"github.com/vegarsti/extract/records"
+	"github.com/vegarsti/extract/sql"
 	"github.com/vegarsti/extract/textract"
+	"github.com/vegarsti/extract/xlsx"
 )
 
 var awsRegion string
 
+// formats the table can be written to stdout in
+var formats = []string{"text", "markdown", "csv", "json", "ndjson", "split", "sql", "latex", "html"}
+
+var format = flag.String("format", "text", "format of the table written to stdout: "+strings.Join(formats, ", "))
+var columnDetection = flag.String("columns", "boxes", "how columns are found: boxes, or projection for the gutters in the projection profile of the words")
+var minSupport = flag.Float64("min-support", extract.DefaultMinSupport, "fraction of rows that may cover a gutter with the projection columns")
+var sqlDialect = flag.String("sql-dialect", string(sql.PostgreSQL), "dialect of the sql format: postgresql, sqlite or mysql")
+
 func main() {
-	if len(os.Args) < 2 || len(os.Args) > 3 {
-		fmt.Fprintf(os.Stderr, "usage: extract-table [file ...] isPDF \n")
+	flag.Parse()
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		fmt.Fprintf(os.Stderr, "usage: extract-table [-format %s] [-columns boxes|projection] [file ...] isPDF \n", strings.Join(formats, "|"))
 		os.Exit(1)
 	}
+	if !validFormat(*format) {
+		die(fmt.Errorf("invalid format '%s', must be one of %s", *format, strings.Join(formats, ", ")))
+	}
+	if *columnDetection != "boxes" && *columnDetection != "projection" {
+		die(fmt.Errorf("invalid columns '%s', must be boxes or projection", *columnDetection))
+	}
+	sqlOptions, err := sql.ParseOptions(sql.Default, map[string]string{"dialect": *sqlDialect})
+	if err != nil {
+		die(err)
+	}
 	if err := readEnvVars(); err != nil {
 		die(err)
 	}
-	filename := os.Args[1]
+	filename := flag.Arg(0)
 	imageBytes, err := os.ReadFile(filename)
 	if err != nil {
 		die(err)
@@ -45,17 +74,16 @@ func main() {
 
 	// Check if table is stored
 	checksum := fmt.Sprintf("%x", sha256.Sum256(imageBytes))
-	fmt.Println(checksum)
+	// the table is written to stdout
+	log.Printf("checksum: %s", checksum)
 
 	// Get from cache
-	// storedBytes, err := dynamodb.GetTable(checksum)
+	// stored, err := dynamodb.GetTable(checksum)
 	// if err != nil {
 	// 	die(err)
 	// }
-	// if storedBytes != nil {
-	// 	var table [][]string
-	// 	json.Unmarshal(storedBytes, &table)
-	// 	writeTable(table)
+	// if stored != nil {
+	// 	writeTable(os.Stdout, stored, normalize.Detect(stored.Body()), *format, sqlOptions)
 	// 	return
 	// }
 
@@ -81,7 +109,31 @@ func main() {
 		panic(err)
 	}
 
-	rows, table := box.ToTable(boxes)
+	var table *extract.Table
+	rows := make([][]box.Box, 0)
+	if *columnDetection == "projection" {
+		// the words are put in columns without positions, so there are no row boxes to draw
+		stringRows, err := textract.ToTableWithProjectionProfile(output, *minSupport)
+		if err != nil {
+			die(fmt.Errorf("failed to find columns: %w", err))
+		}
+		table = extract.NewTable(stringRows)
+	} else {
+		rows, _ = box.ToTable(boxes)
+		var decisions []string
+		rows, _, decisions = box.Prune(rows)
+		for _, decision := range decisions {
+			log.Printf("prune: %s", decision)
+		}
+		columns, headerRows := header.Flatten(rows, boxes)
+		table = &extract.Table{
+			Rows:       extract.CellsFromBoxes(rows),
+			Columns:    columns,
+			HeaderRows: headerRows,
+			Pages:      box.Pages(boxes),
+			Direction:  "ltr",
+		}
+	}
 
 	// Add boxes
 	if contentType == extract.PNG {
@@ -118,21 +170,37 @@ func main() {
 		die(err)
 	}
 
-	fmt.Printf("%+v\n", table)
+	tableJSON, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		die(err)
+	}
+	filenameTableJSON := strings.TrimSuffix(filename, filepath.Ext(filename)) + "_table.json"
+	if err := os.WriteFile(filenameTableJSON, tableJSON, 0644); err != nil {
+		die(err)
+	}
+
+	l := normalize.Detect(table.Body())
+	workbook, err := xlsx.FromTable(table, l)
+	if err != nil {
+		die(err)
+	}
+	filenameXLSX := strings.TrimSuffix(filename, filepath.Ext(filename)) + "_table.xlsx"
+	if err := os.WriteFile(filenameXLSX, workbook, 0644); err != nil {
+		die(err)
+	}
+
+	if err := writeTable(os.Stdout, table, l, *format, sqlOptions); err != nil {
+		die(err)
+	}
 	// filenameTable := strings.TrimSuffix(filename, filepath.Ext(filename)) + "_table.txt"
 	// f, err := os.Create(filenameTable)
 	// if err != nil {
 	// 	die(err)
 	// }
-	// writeTable(bufio.NewWriter(f), table)
-	// writeTable(os.Stdout, table)
+	// writeTable(bufio.NewWriter(f), table, l, *format, sqlOptions)
 
 	// store in dynamo db
-	// tableJSON, err := json.Marshal(table)
-	// if err != nil {
-	// 	die(err)
-	// }
-	// if err := dynamodb.PutTable(checksum[:], tableJSON, []byte{}); err != nil {
+	// if err := dynamodb.PutTable(checksum[:], file.ContentType, table, []byte{}); err != nil {
 	// 	die(err)
 	// }
 }
@@ -150,18 +218,55 @@ func die(err error) {
 	os.Exit(1)
 }
 
-// writeTeable to stdout
-func writeTable(wr io.Writer, table [][]string) {
-	fmt.Println(table)
-	w := tabwriter.NewWriter(wr, 4, 4, 2, ' ', tabwriter.Debug)
-	for _, row := range table {
-		for j, cell := range row {
-			fmt.Fprint(w, cell)
-			if j < len(row)-1 {
-				fmt.Fprintf(w, "\t")
-			}
+func validFormat(format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
 		}
-		fmt.Fprintf(w, "\n")
 	}
-	w.Flush()
+	return false
+}
+
+// writeTable in the format, where numbers are written in the locale l
+func writeTable(wr io.Writer, table *extract.Table, l normalize.Locale, format string, sqlOptions sql.Options) error {
+	var s string
+	switch format {
+	case "markdown":
+		s = markdown.FromTable(table, l)
+	case "csv":
+		var err error
+		if s, err = csv.FromTable(table, csv.Default); err != nil {
+			return err
+		}
+	case "json":
+		bs, err := json.MarshalIndent(table, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to convert table to json: %w", err)
+		}
+		s = string(bs) + "\n"
+	case "ndjson":
+		bs, err := records.NDJSON(table, false)
+		if err != nil {
+			return fmt.Errorf("failed to convert table to ndjson: %w", err)
+		}
+		s = string(bs)
+	case "split":
+		bs, err := records.Split(table, false)
+		if err != nil {
+			return fmt.Errorf("failed to convert table to split json: %w", err)
+		}
+		s = string(bs) + "\n"
+	case "latex":
+		s = latex.FromTable(table, l)
+	case "html":
+		s = html.Fragment(table)
+	case "sql":
+		s = sql.FromTable(table, sqlOptions, l)
+	default:
+		s = plaintext.FromTable(table, l)
+	}
+	if _, err := io.WriteString(wr, s); err != nil {
+		return fmt.Errorf("write table: %w", err)
+	}
+	return nil
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -10,6 +11,8 @@ import (
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,12 +20,26 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/vegarsti/extract"
 	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/correct"
 	"github.com/vegarsti/extract/csv"
+	"github.com/vegarsti/extract/detail"
 	"github.com/vegarsti/extract/dynamodb"
+	"github.com/vegarsti/extract/header"
+	"github.com/vegarsti/extract/hierarchy"
 	"github.com/vegarsti/extract/html"
 	"github.com/vegarsti/extract/image"
+	"github.com/vegarsti/extract/latex"
+	"github.com/vegarsti/extract/markdown"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/plaintext"
+	"github.com/vegarsti/extract/records"
 	"github.com/vegarsti/extract/s3"
+	"github.com/vegarsti/extract/schema"
+	"github.com/vegarsti/extract/sql"
+	"github.com/vegarsti/extract/stitch"
 	"github.com/vegarsti/extract/textract"
+	"github.com/vegarsti/extract/verify"
+	"github.com/vegarsti/extract/xlsx"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -34,6 +51,10 @@ func HandleRequest(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyRe
 		log.Printf("%s: %s", header, value)
 	}
 
+	if strings.HasPrefix(req.Path, correctionsPath) {
+		return handleCorrection(req, reqHeaders), nil
+	}
+
 	if !req.IsBase64Encoded {
 		return errorResponse(fmt.Errorf(
 			"request body must have a content-type that is either image/png, image/jpeg, application/pdf, multipart/form-data or application/x-www-form-urlencoded, got '%s'",
@@ -71,40 +92,349 @@ func HandleRequest(req events.APIGatewayProxyRequest) (*events.APIGatewayProxyRe
 		return errorResponse(err), nil
 	}
 
+	opts, err := parseOptions(req.QueryStringParameters)
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
 	// get table, from cache if possible, if not from textract
-	table, err := getTable(file)
+	result, err := getTable(file, opts)
 	if err != nil {
 		return errorResponse(err), nil
 	}
-	tableBytes, err := json.MarshalIndent(table, "", "  ")
+	l := normalize.Detect(result.table.Body())
+	if opts.locale != nil {
+		l = *opts.locale
+	}
+	log.Printf("locale: %s", l.Name)
+	if opts.correct {
+		result.correct(l)
+	}
+	if opts.normalize || opts.typed {
+		result.normalize(l)
+	}
+	var verification *verify.Report
+	if opts.verify {
+		verification = result.verify(l)
+	}
+	tableBytes, err := json.MarshalIndent(result.table.Strings(), "", "  ")
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert to json: %w", err)
 	}
 
-	responseMediaType, err := determineResponseMediaType(reqHeaders["accept"])
+	responseMediaType, mediaTypeParams, err := determineResponseMediaType(reqHeaders["accept"])
 	if err != nil {
 		return errorResponse(err), nil
 	}
-	url := "https://results.extract-table.com/" + file.Checksum
+	// the CSV dialect is set with parameters in the Accept header, e.g. text/csv; delimiter=semicolon,
+	// or with the same query options, which take precedence
+	opts.dialect = csv.Default
+	if responseMediaType == csv.TSVMediaType {
+		opts.dialect = csv.TSV
+	}
+	if opts.dialect, err = csv.ParseDialect(opts.dialect, mediaTypeParams); err != nil {
+		return errorResponse(err), nil
+	}
+	if opts.dialect, err = csv.ParseDialect(opts.dialect, req.QueryStringParameters); err != nil {
+		return errorResponse(err), nil
+	}
+	// a fragment can also be asked for with text/html; fragment=true
+	if fragment, err := parseBool(mediaTypeParams, "fragment"); err != nil {
+		return errorResponse(err), nil
+	} else if fragment {
+		opts.fragment = true
+	}
+	// and so is the SQL dialect and the name of the table, e.g. application/sql; dialect=sqlite
+	if opts.sql, err = sql.ParseOptions(sql.Default, mediaTypeParams); err != nil {
+		return errorResponse(err), nil
+	}
+	if opts.sql, err = sql.ParseOptions(opts.sql, req.QueryStringParameters); err != nil {
+		return errorResponse(err), nil
+	}
+	url := "https://results.extract-table.com/" + resultKey(file.Checksum, opts.table)
 	log.Println(url)
 	log.Printf("responsemediatype is %s", responseMediaType)
+	response, err := respond(responseMediaType, url, result, tableBytes, opts, l, verification)
+	if response != nil {
+		// the response only has one table, so tell how many there are to choose from with ?table=
+		response.Headers["X-Extract-Tables"] = strconv.Itoa(result.tables)
+	}
+	if response != nil && verification != nil {
+		// flag tables where the totals don't add up, whatever the format of the response
+		response.Headers["X-Extract-Verification"] = "ok"
+		if !verification.OK {
+			response.Headers["X-Extract-Verification"] = "mismatch"
+		}
+	}
+	return response, err
+}
+
+// corrected tables are sent from the results page to correctionsPath followed by the key of the results, see resultKey
+const correctionsPath = "/corrections/"
+const correctionsURL = "https://api.extract-table.com" + correctionsPath
+
+// corrections are stored in the DynamoDB item of the extracted table and its words, which can be at most 400 KB
+const maxCorrectionBytes = 200 << 10
+const maxCorrectionRows = 1000
+const maxCorrectionColumns = 100
+
+var resultKeyRegexp = regexp.MustCompile(`^([0-9a-f]{64})(?:-([0-9]+))?$`)
+
+// resultKey is the key the results of a table in the file with the checksum are stored under:
+// the checksum for the first table, and the checksum followed by the number of the table for the others
+func resultKey(checksum string, table int) string {
+	if table <= 1 {
+		return checksum
+	}
+	return fmt.Sprintf("%s-%d", checksum, table)
+}
+
+// handleCorrection stores a table that has been corrected on the results page next to the extracted table,
+// and uploads the results again so the downloads have the corrected table.
+// The results page is on another domain, so the browser asks for permission first.
+// reqHeaders are the headers of the request with lower-case names.
+func handleCorrection(req events.APIGatewayProxyRequest, reqHeaders map[string]string) *events.APIGatewayProxyResponse {
+	response := storeCorrection(req, reqHeaders)
+	response.Headers["Access-Control-Allow-Origin"] = "https://results.extract-table.com"
+	response.Headers["Access-Control-Allow-Methods"] = "PUT, OPTIONS"
+	response.Headers["Access-Control-Allow-Headers"] = "Content-Type, api-key"
+	return response
+}
+
+// storeCorrection stores the corrected table and responds with it as it was stored.
+// The results pages are public, so a correction must be sent with an API key.
+func storeCorrection(req events.APIGatewayProxyRequest, reqHeaders map[string]string) *events.APIGatewayProxyResponse {
+	if req.HTTPMethod == http.MethodOptions {
+		return &events.APIGatewayProxyResponse{Headers: map[string]string{}, StatusCode: http.StatusNoContent}
+	}
+	if req.HTTPMethod != http.MethodPut {
+		response := errorResponse(fmt.Errorf("corrections must be sent with PUT, got %s", req.HTTPMethod))
+		response.StatusCode = http.StatusMethodNotAllowed
+		return response
+	}
+	apiKey := reqHeaders["api-key"]
+	if apiKey == "" {
+		response := errorResponse(fmt.Errorf("no api-key was provided, corrections must be sent with the api-key header"))
+		response.StatusCode = http.StatusUnauthorized
+		return response
+	}
+	valid, err := dynamodb.VerifyAPIKey(apiKey)
+	if err != nil {
+		return errorResponse(fmt.Errorf("verify api key: %w", err))
+	}
+	if !valid {
+		response := errorResponse(fmt.Errorf("API key '%s' is invalid", apiKey))
+		response.StatusCode = http.StatusUnauthorized
+		return response
+	}
+	key := strings.TrimPrefix(req.Path, correctionsPath)
+	m := resultKeyRegexp.FindStringSubmatch(key)
+	if m == nil {
+		return errorResponse(fmt.Errorf("invalid key '%s'", key))
+	}
+	checksum := m[1]
+	tableNumber := 1
+	if m[2] != "" {
+		if tableNumber, err = strconv.Atoi(m[2]); err != nil || tableNumber < 1 {
+			return errorResponse(fmt.Errorf("invalid key '%s'", key))
+		}
+	}
+	body := []byte(req.Body)
+	if req.IsBase64Encoded {
+		if body, err = base64.StdEncoding.DecodeString(req.Body); err != nil {
+			return errorResponse(fmt.Errorf("unable to convert base64 to bytes: %w", err))
+		}
+	}
+	if len(body) > maxCorrectionBytes {
+		response := errorResponse(fmt.Errorf("the table is %d bytes, but can be at most %d", len(body), maxCorrectionBytes))
+		response.StatusCode = http.StatusRequestEntityTooLarge
+		return response
+	}
+	var table extract.Table
+	if err := json.Unmarshal(body, &table); err != nil {
+		return errorResponse(fmt.Errorf("invalid table: %w", err))
+	}
+	if err := tidyCorrection(&table); err != nil {
+		return errorResponse(err)
+	}
+
+	if err := dynamodb.PutCorrectedTable(key, &table); err != nil {
+		if errors.Is(err, dynamodb.ErrNotFound) {
+			response := errorResponse(err)
+			response.StatusCode = http.StatusNotFound
+			return response
+		}
+		log.Printf("dynamodb.PutCorrectedTable: %v", err)
+		return errorResponse(fmt.Errorf("unable to store the corrected table"))
+	}
+	log.Printf("stored corrected table for %s", key)
+
+	fileType, boxesJSON, err := dynamodb.GetSource(key)
+	if err != nil {
+		log.Printf("dynamodb.GetSource: %v", err)
+		return errorResponse(fmt.Errorf("unable to get the file the table was extracted from"))
+	}
+	var words []box.Box
+	if boxesJSON != nil {
+		if err := json.Unmarshal(boxesJSON, &words); err != nil {
+			log.Printf("failed to convert boxes from json: %v", err)
+		}
+	}
+	g := new(errgroup.Group)
+	if err := uploadResults(g, key, fileType, &table, words, resultURLs(checksum, tableNumber, fileType)); err != nil {
+		log.Printf("upload results: %v", err)
+		return errorResponse(fmt.Errorf("unable to update the results"))
+	}
+	if err := g.Wait(); err != nil {
+		log.Printf("upload results: %v", err)
+		return errorResponse(fmt.Errorf("unable to update the results"))
+	}
+
+	tableBytes, err := json.Marshal(table)
+	if err != nil {
+		return errorResponse(fmt.Errorf("failed to convert to json: %w", err))
+	}
+	return successResponse(string(tableBytes)+"\n", "application/json")
+}
+
+// tidyCorrection checks that the corrected table is a table, makes every row as wide as the widest,
+// and names the columns after the header rows, which may have been edited
+func tidyCorrection(table *extract.Table) error {
+	if len(table.Rows) == 0 {
+		return fmt.Errorf("the table has no rows")
+	}
+	if len(table.Rows) > maxCorrectionRows {
+		return fmt.Errorf("the table has %d rows, but can have at most %d", len(table.Rows), maxCorrectionRows)
+	}
+	if table.HeaderRows < 0 || table.HeaderRows > len(table.Rows) {
+		return fmt.Errorf("the table has %d rows, so it can't have %d header rows", len(table.Rows), table.HeaderRows)
+	}
+	width := 0
+	for _, row := range table.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	if width > maxCorrectionColumns {
+		return fmt.Errorf("the table has %d columns, but can have at most %d", width, maxCorrectionColumns)
+	}
+	for i := range table.Rows {
+		for len(table.Rows[i]) < width {
+			table.Rows[i] = append(table.Rows[i], extract.Cell{})
+		}
+		for j := range table.Rows[i] {
+			cell := &table.Rows[i][j]
+			if cell.RowSpan < 1 {
+				cell.RowSpan = 1
+			}
+			if cell.ColumnSpan < 1 {
+				cell.ColumnSpan = 1
+			}
+		}
+	}
+	table.Columns = header.Columns(table)
+	if table.Pages == nil {
+		table.Pages = make([]int, 0)
+	}
+	if table.Direction == "" {
+		table.Direction = "ltr"
+	}
+	return nil
+}
+
+// respond with the table in the requested media type
+func respond(responseMediaType string, url string, result *result, tableBytes []byte, opts options, l normalize.Locale, verification *verify.Report) (*events.APIGatewayProxyResponse, error) {
 	switch responseMediaType {
 	case "text/html":
+		if opts.fragment {
+			return successResponse(html.Fragment(result.table), "text/html"), nil
+		}
 		return &events.APIGatewayProxyResponse{
 			Headers: map[string]string{
 				"Location": url,
 			},
 			StatusCode: 301,
 		}, nil
-	case "text/csv":
-		csvBody := csv.FromTable(table)
-		return successResponse(csvBody, "text/csv"), nil
+	case csv.MediaType, csv.TSVMediaType:
+		csvBody, err := csv.FromTable(result.table, opts.dialect)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert to csv: %w", err)
+		}
+		return successResponse(csvBody, responseMediaType), nil
+	case detail.MediaType:
+		return detailedResponse(result, detail.MediaType)
+	case latex.MediaType:
+		return successResponse(latex.FromTable(result.table, l), latex.MediaType), nil
+	case markdown.MediaType:
+		return successResponse(markdown.FromTable(result.table, l), markdown.MediaType), nil
+	case plaintext.MediaType:
+		return successResponse(plaintext.FromTable(result.table, l), plaintext.MediaType), nil
+	case sql.MediaType:
+		return successResponse(sql.FromTable(result.table, opts.sql, l), sql.MediaType), nil
+	case records.NDJSONMediaType:
+		ndjsonBytes, err := records.NDJSON(result.table, opts.typed)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert to ndjson: %w", err)
+		}
+		return successResponse(string(ndjsonBytes), records.NDJSONMediaType), nil
+	case xlsx.MediaType:
+		sheets := []xlsx.Sheet{{Name: "Table", Table: result.table}}
+		if opts.sheets == "page" {
+			sheets = xlsx.ByPage(result.table)
+		}
+		xlsxBytes, err := xlsx.FromSheets(sheets, l)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert to xlsx: %w", err)
+		}
+		return binaryResponse(xlsxBytes, xlsx.MediaType), nil
 	default:
+		if opts.detail == "full" {
+			return detailedResponse(result, "application/json")
+		}
+		if opts.orient == "records" {
+			recordsBytes, err := records.FromTable(result.table, opts.typed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert to records: %w", err)
+			}
+			return successResponse(string(recordsBytes)+"\n", "application/json"), nil
+		}
+		if opts.orient == "split" {
+			splitBytes, err := records.Split(result.table, opts.typed)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert to split: %w", err)
+			}
+			return successResponse(string(splitBytes)+"\n", "application/json"), nil
+		}
+		if opts.typed {
+			columnSchema := schema.Infer(result.table.Columns, result.table.Body())
+			typed := schema.Typed(columnSchema, result.table.Body())
+			typed.Locale = result.table.Locale
+			typed.Raw = result.raw
+			response := typedResponse{
+				TypedTable:   typed,
+				Corrections:  result.corrections,
+				Verification: verification,
+			}
+			typedBytes, err := json.MarshalIndent(response, "", "  ")
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert to typed json: %w", err)
+			}
+			return successResponse(string(typedBytes)+"\n", "application/json"), nil
+		}
 		jsonBody := string(tableBytes) + "\n"
 		return successResponse(jsonBody, "application/json"), nil
 	}
 }
 
+// detailedResponse has the geometry and words of every cell
+func detailedResponse(result *result, mediaType string) (*events.APIGatewayProxyResponse, error) {
+	detailBytes, err := json.MarshalIndent(detail.FromTable(result.table, result.words), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert to detailed json: %w", err)
+	}
+	return successResponse(string(detailBytes)+"\n", mediaType), nil
+}
+
 func errorResponse(err error) *events.APIGatewayProxyResponse {
 	return &events.APIGatewayProxyResponse{
 		Headers:    map[string]string{"Content-Type": "application/json"},
@@ -121,24 +451,235 @@ func successResponse(body string, mediaType string) *events.APIGatewayProxyRespo
 	}
 }
 
+// binaryResponse is base64 encoded, which API Gateway decodes before responding
+func binaryResponse(body []byte, mediaType string) *events.APIGatewayProxyResponse {
+	return &events.APIGatewayProxyResponse{
+		Headers:         map[string]string{"Content-Type": mediaType},
+		StatusCode:      200,
+		Body:            base64.StdEncoding.EncodeToString(body),
+		IsBase64Encoded: true,
+	}
+}
+
 func main() {
 	lambda.Start(HandleRequest)
 }
 
+// options are set per request with query parameters, e.g. ?orient=records
+type options struct {
+	// orient is the layout of JSON responses: "values" for an array of rows (the default),
+	// "records" for an array of objects keyed by the column names,
+	// or "split" for an object with the columns and the rows, as in pandas
+	orient string
+	// pageColumn adds a column with the page number of each row
+	pageColumn bool
+	// typed JSON responses have values with the inferred type of their column,
+	// and the inferred schema
+	typed bool
+	// normalize numbers and dates, e.g. "1.234,56" is "1234.56" and "31.12.2021" is "2021-12-31".
+	// The raw text is kept in typed JSON and in the cells of detailed JSON, but the other formats,
+	// including values, records and split JSON, only have the normalized text.
+	normalize bool
+	// locale is the locale numbers and dates are written in, detected from the table if nil
+	locale *normalize.Locale
+	// correct characters that OCR confused for digits in numeric columns
+	correct bool
+	// level adds a column with the indentation level of the first column of each row
+	level bool
+	// path adds a column with the path through the indentation levels to each row,
+	// e.g. "Operating costs > Salaries"
+	path bool
+	// verify that the line items sum to the total rows
+	verify bool
+	// checkboxes finds selection elements such as checkboxes, which are true if ticked
+	checkboxes bool
+	// direction of the columns: "ltr" for left to right (the default), "rtl" for right to left,
+	// or "auto" for right to left if most of the text is in a right-to-left script
+	direction string
+	// mergeColumns merges adjacent columns that are a single field split at a word gap,
+	// e.g. "First Last" under a "Name" header
+	mergeColumns bool
+	// sheets of spreadsheet responses: "table" for the whole table in one sheet (the default),
+	// or "page" for one sheet per page
+	sheets string
+	// detail of JSON responses: "full" for the geometry and words of every cell,
+	// like the application/vnd.extract-table+json media type
+	detail string
+	// dialect of CSV responses
+	dialect csv.Dialect
+	// fragment responds to text/html with a bare <table> element instead of redirecting to the results page
+	fragment bool
+	// sql has the dialect of SQL responses and the name of the table
+	sql sql.Options
+	// table is the number of the table to respond with, counting from 1, when the file has several
+	// tables that don't continue on each other's pages. Each table has its own results page.
+	table int
+}
+
+func parseOptions(params map[string]string) (options, error) {
+	var opts options
+	opts.orient = params["orient"]
+	switch opts.orient {
+	case "", "values", "records", "split":
+	default:
+		return options{}, fmt.Errorf("invalid value for orient: '%s', must be either values, records or split", opts.orient)
+	}
+	var err error
+	if opts.pageColumn, err = parseBool(params, "page_column"); err != nil {
+		return options{}, err
+	}
+	if opts.typed, err = parseBool(params, "typed"); err != nil {
+		return options{}, err
+	}
+	if opts.normalize, err = parseBool(params, "normalize"); err != nil {
+		return options{}, err
+	}
+	if opts.correct, err = parseBool(params, "correct"); err != nil {
+		return options{}, err
+	}
+	if opts.verify, err = parseBool(params, "verify"); err != nil {
+		return options{}, err
+	}
+	if opts.checkboxes, err = parseBool(params, "checkboxes"); err != nil {
+		return options{}, err
+	}
+	if v := params["hierarchy"]; v != "" {
+		for _, column := range strings.Split(v, ",") {
+			switch strings.TrimSpace(column) {
+			case "level":
+				opts.level = true
+			case "path":
+				opts.path = true
+			default:
+				return options{}, fmt.Errorf("invalid value for hierarchy: '%s', must be level, path or both separated by comma", v)
+			}
+		}
+	}
+	if opts.fragment, err = parseBool(params, "fragment"); err != nil {
+		return options{}, err
+	}
+	if opts.mergeColumns, err = parseBool(params, "merge_columns"); err != nil {
+		return options{}, err
+	}
+	opts.direction = params["direction"]
+	switch opts.direction {
+	case "", "ltr", "rtl", "auto":
+	default:
+		return options{}, fmt.Errorf("invalid value for direction: '%s', must be either ltr, rtl or auto", opts.direction)
+	}
+	opts.detail = params["detail"]
+	switch opts.detail {
+	case "", "full":
+	default:
+		return options{}, fmt.Errorf("invalid value for detail: '%s', must be full", opts.detail)
+	}
+	opts.sheets = params["sheets"]
+	switch opts.sheets {
+	case "", "table", "page":
+	default:
+		return options{}, fmt.Errorf("invalid value for sheets: '%s', must be either table or page", opts.sheets)
+	}
+	opts.table = 1
+	if v := params["table"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return options{}, fmt.Errorf("invalid value for table: '%s', must be a number from 1", v)
+		}
+		opts.table = n
+	}
+	if tag := params["locale"]; tag != "" && tag != "auto" {
+		l, err := normalize.ParseLocale(tag)
+		if err != nil {
+			return options{}, err
+		}
+		opts.locale = &l
+	}
+	return opts, nil
+}
+
+// parseBool parses the query parameter, which is false if not set
+func parseBool(params map[string]string, name string) (bool, error) {
+	v, ok := params[name]
+	if !ok {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: '%s', must be either true or false", name, v)
+	}
+	return b, nil
+}
+
+// result is an extracted table along with what was done to it
+type result struct {
+	table *extract.Table
+	// words are the word boxes the table was made from
+	words []box.Box
+	// raw is the text in the body of the table before it was corrected and normalized
+	raw [][]string
+	// corrections are the OCR errors that were corrected in the body of the table
+	corrections []correct.Substitution
+	// levels are the indentation levels of the first column of the rows in the body of the table
+	levels []int
+	// tables is the number of tables found in the file, of which table is one
+	tables int
+	// added are the columns added by the options, such as the page of each row
+	added []int
+}
+
+// setBody replaces the rows below the header, keeping the raw text
+func (r *result) setBody(body [][]string) {
+	if r.raw == nil {
+		r.raw = r.table.Body()
+	}
+	r.table.SetBody(body)
+}
+
+// correct OCR errors in numeric columns of the body of the table
+func (r *result) correct(l normalize.Locale) {
+	body, corrections := correct.Numeric(r.table.Body(), l)
+	for _, c := range corrections {
+		log.Printf("corrected row %d, column %d: '%s' -> '%s'", c.Row, c.Column, c.From, c.To)
+	}
+	r.corrections = corrections
+	r.setBody(body)
+}
+
+// normalize numbers and dates in the body of the table, keeping the raw text
+func (r *result) normalize(l normalize.Locale) {
+	cells := normalize.Table(r.table.Body(), l)
+	r.setBody(normalize.Normalized(cells))
+	r.table.Locale = l.Name
+}
+
+// verify that the totals in the table add up
+func (r *result) verify(l normalize.Locale) *verify.Report {
+	report := verify.Verify(r.table.Columns, normalize.Body(r.table, l), r.added)
+	for _, check := range report.Checks {
+		for _, m := range check.Mismatches {
+			log.Printf("total in row %d, column %d is %f, but the sum is %f", check.Row, m.Column, m.Total, m.Sum)
+		}
+	}
+	return &report
+}
+
+// typedResponse is the response for typed JSON
+type typedResponse struct {
+	*schema.TypedTable
+	Corrections  []correct.Substitution `json:"corrections,omitempty"`
+	Verification *verify.Report         `json:"verification,omitempty"`
+}
+
 // getTable either cached from DynamoDB if it has been processed before, or perform OCR with Textract
-func getTable(file *extract.File) ([][]string, error) {
+func getTable(file *extract.File, opts options) (*result, error) {
 	// startGet := time.Now()
-	// tableBytes, err := dynamodb.GetTable(file.Checksum)
+	// table, err := dynamodb.GetTable(file.Checksum)
 	// if err != nil {
 	// 	return nil, fmt.Errorf("dynamodb.GetTable: %w", err)
 	// }
 	// log.Printf("dynamodb get: %s", time.Since(startGet).String())
-	// if tableBytes != nil {
-	// 	var table [][]string
-	// 	if err := json.Unmarshal(tableBytes, &table); err != nil {
-	// 		return nil, fmt.Errorf("failed to convert from json: %w", err)
-	// 	}
-	// 	return table, nil
+	// if table != nil {
+	// 	return &result{table: table}, nil
 	// }
 	// Old: Textract's Analyze Document
 	// output, err := textract.AnalyzeDocument(file)
@@ -146,19 +687,80 @@ func getTable(file *extract.File) ([][]string, error) {
 	// 	return nil, fmt.Errorf("failed to extract: %w", err)
 	// }
 	startOCR := time.Now()
-	// Don't use Textract's Analyze Document, use OCR and custom algorithm instead
-	output, err := textract.DetectDocumentText(file)
-	log.Printf("textract: %s", time.Since(startOCR).String())
-	if err != nil {
-		return nil, fmt.Errorf("textract text detection failed: %w", err)
+	// Don't use Textract's Analyze Document, use OCR and custom algorithm instead,
+	// unless we need the selection elements that only document analysis finds
+	var boxes []box.Box
+	if opts.checkboxes {
+		output, err := textract.AnalyzeDocument(file)
+		log.Printf("textract: %s", time.Since(startOCR).String())
+		if err != nil {
+			return nil, fmt.Errorf("textract document analysis failed: %w", err)
+		}
+		boxes, err = textract.ToBoxesFromAnalysis(output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert to boxes: %w", err)
+		}
+	} else {
+		output, err := textract.DetectDocumentText(file)
+		log.Printf("textract: %s", time.Since(startOCR).String())
+		if err != nil {
+			return nil, fmt.Errorf("textract text detection failed: %w", err)
+		}
+		boxes, err = textract.ToBoxesFromOCR(output)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert to boxes: %w", err)
+		}
 	}
 	startAlgorithm := time.Now()
-	boxes, err := textract.ToBoxesFromOCR(output)
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert to boxes: %w", err)
+	// tables continuing across pages of a PDF are stitched together
+	tables := stitch.Stitch(boxes)
+	log.Printf("tables: %d, responding with table %d", len(tables), opts.table)
+	if opts.table > len(tables) && opts.table > 1 {
+		return nil, fmt.Errorf("there is no table %d, the file has %d tables", opts.table, len(tables))
+	}
+	stitched := &stitch.Table{}
+	if len(tables) > 0 {
+		stitched = tables[opts.table-1]
+	}
+	for _, decision := range stitched.Prune() {
+		log.Printf("prune: %s", decision)
+	}
+	if opts.mergeColumns {
+		var decisions []string
+		stitched.Rows, decisions = box.MergeSplitColumns(stitched.Rows, boxes, header.Detect(stitched.Rows))
+		for _, decision := range decisions {
+			log.Printf("merge: %s", decision)
+		}
+	}
+	columns := make([]string, 0)
+	var headerRows int
+	if len(stitched.Rows) > 0 {
+		columns, headerRows = header.Flatten(stitched.Rows, box.OnPage(boxes, stitched.Pages[0]))
+	}
+	direction := "ltr"
+	if opts.direction == "rtl" || (opts.direction == "auto" && box.IsRTL(stitched.Rows)) {
+		// the first column is to the right
+		direction = "rtl"
+		stitched.Rows = box.ReverseColumns(stitched.Rows)
+		for j, k := 0, len(columns)-1; j < k; j, k = j+1, k-1 {
+			columns[j], columns[k] = columns[k], columns[j]
+		}
+	}
+	pages := make([]int, 0)
+	for _, page := range stitched.Pages {
+		if len(pages) == 0 || pages[len(pages)-1] != page {
+			pages = append(pages, page)
+		}
+	}
+	extracted := &extract.Table{
+		Rows:       extract.CellsFromBoxes(stitched.Rows),
+		Columns:    columns,
+		HeaderRows: headerRows,
+		Pages:      pages,
+		Direction:  direction,
 	}
-	rowsBoxesUnsorted, tableStringsSorted := box.ToTable(boxes)
 	log.Printf("ocr-to-table: %s", time.Since(startAlgorithm).String())
+	log.Printf("header rows: %d", headerRows)
 
 	// Create images with words and cells
 	func() {
@@ -169,7 +771,7 @@ func getTable(file *extract.File) ([][]string, error) {
 				return
 			}
 			rowsFlattened := make([]box.Box, 0)
-			for _, row := range rowsBoxesUnsorted {
+			for _, row := range stitched.Rows {
 				rowsFlattened = append(rowsFlattened, row...)
 			}
 			imageWithCells, err := image.AddBoxes(file.Bytes, rowsFlattened)
@@ -182,19 +784,68 @@ func getTable(file *extract.File) ([][]string, error) {
 		}
 	}()
 
-	tableBytes, err := json.MarshalIndent(tableStringsSorted, "", "  ")
+	// a table that has been corrected on the results page replaces the extracted table before the
+	// options of the request are applied. It was corrected as it was shown, so merge_columns and direction,
+	// which change how the words are put in cells, only apply to the extracted table.
+	// The extracted table is still stored so the two can be compared.
+	key := resultKey(file.Checksum, opts.table)
+	table := extracted
+	rowPages := stitched.Pages
+	rows := stitched.Rows
+	corrected, err := dynamodb.GetCorrectedTable(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to convert table to json: %w", err)
+		log.Printf("dynamodb.GetCorrectedTable: %v, using the extracted table", err)
+	}
+	if corrected != nil {
+		log.Printf("table has been corrected")
+		table = corrected
+		rowPages = corrected.RowPages()
+		rows = extract.BoxesFromCells(corrected.Rows)
 	}
 
-	csvBytes := []byte(csv.FromTable(tableStringsSorted))
-	url := "https://results.extract-table.com/" + file.Checksum
-	imageURL := url + ".png" // what about jpg?
-	csvURL := url + ".csv"
-	pdfURL := url + ".pdf"
-	htmlBytes := html.FromTable(tableStringsSorted, file.ContentType, imageURL, csvURL, pdfURL)
-
+	// the results are shared by every request for the file, so they have the table without the options
+	urls := resultURLs(file.Checksum, opts.table, file.ContentType)
+	if len(file.BytesWithBoxes) == 0 || len(file.BytesWithRowBoxes) == 0 {
+		urls.Words = ""
+		urls.Cells = ""
+	}
 	g := new(errgroup.Group)
+	if err := uploadResults(g, key, file.ContentType, table, boxes, urls); err != nil {
+		return nil, err
+	}
+
+	// the options change a copy, which doesn't change what is stored
+	table = table.Copy()
+	result := &result{
+		table:  table,
+		words:  boxes,
+		levels: hierarchy.Levels(rows[table.HeaderRows:], boxes),
+		tables: len(tables),
+	}
+	// the paths are of the first column, so they're found before the page column is added first
+	var paths []string
+	if opts.path {
+		paths = hierarchy.Paths(table.Body(), result.levels)
+	}
+	if opts.pageColumn {
+		stitch.AddPageColumn(table, rowPages)
+		result.added = append(result.added, 0)
+	}
+	if opts.level {
+		levels := make([]string, len(result.levels))
+		for i, level := range result.levels {
+			if level >= 0 {
+				levels[i] = strconv.Itoa(level)
+			}
+		}
+		table.AddColumn("Level", levels)
+		result.added = append(result.added, table.Width()-1)
+	}
+	if opts.path {
+		table.AddColumn("Path", paths)
+		result.added = append(result.added, table.Width()-1)
+	}
+
 	g.Go(func() error {
 		startUpload := time.Now()
 		if err := s3.UploadPNG(file.Checksum, file.Bytes); err != nil {
@@ -203,29 +854,13 @@ func getTable(file *extract.File) ([][]string, error) {
 		log.Printf("s3 png %s", time.Since(startUpload).String())
 		return nil
 	})
-	g.Go(func() error {
-		startUpload := time.Now()
-		if err := s3.UploadCSV(file.Checksum, csvBytes); err != nil {
-			return err
-		}
-		log.Printf("s3 csv %s", time.Since(startUpload).String())
-		return nil
-	})
-	g.Go(func() error {
-		startUpload := time.Now()
-		if err := s3.UploadHTML(file.Checksum, htmlBytes); err != nil {
-			return err
-		}
-		log.Printf("s3 html %s", time.Since(startUpload).String())
-		return nil
-	})
 	g.Go(func() error {
 		startPut := time.Now()
 		boxesJSON, err := json.Marshal(boxes)
 		if err != nil {
 			return fmt.Errorf("failed to convert boxes to json: %w", err)
 		}
-		if err := dynamodb.PutTable(file.Checksum, tableBytes, boxesJSON); err != nil {
+		if err := dynamodb.PutTable(key, file.ContentType, extracted, boxesJSON); err != nil {
 			return fmt.Errorf("dynamodb.PutTable: %w", err)
 		}
 		log.Printf("dynamodb put: %s", time.Since(startPut).String())
@@ -247,7 +882,7 @@ func getTable(file *extract.File) ([][]string, error) {
 	if file.ContentType == extract.PNG && len(file.BytesWithRowBoxes) > 0 {
 		g.Go(func() error {
 			startUpload := time.Now()
-			if err := s3.UploadPNG(file.Checksum+"_rows", file.BytesWithRowBoxes); err != nil {
+			if err := s3.UploadPNG(key+"_rows", file.BytesWithRowBoxes); err != nil {
 				return err
 			}
 			log.Printf("s3 png %s", time.Since(startUpload).String())
@@ -260,7 +895,68 @@ func getTable(file *extract.File) ([][]string, error) {
 		return nil, err
 	}
 	log.Printf("errgroup: %s", time.Since(startErrgroup).String())
-	return tableStringsSorted, nil
+	return result, nil
+}
+
+// resultURLs are the URLs of the results of the table with the number in the file with the checksum.
+// The file and the image with the words drawn on it are shared by the tables in the file.
+// The images with the words and cells drawn on them are only made for PNG images.
+func resultURLs(checksum string, table int, fileType extract.FileType) html.URLs {
+	fileURL := "https://results.extract-table.com/" + checksum
+	url := "https://results.extract-table.com/" + resultKey(checksum, table)
+	urls := html.URLs{
+		Image:       fileURL + ".png", // what about jpg?
+		PDF:         fileURL + ".pdf",
+		CSV:         url + ".csv",
+		JSON:        url + ".json",
+		Corrections: correctionsURL + resultKey(checksum, table),
+	}
+	if fileType == extract.PNG {
+		urls.Words = fileURL + "_boxes.png"
+		urls.Cells = url + "_rows.png"
+	}
+	return urls
+}
+
+// uploadResults uploads the table as CSV, as detailed JSON and as the results page linking to them,
+// under the key of the results, see resultKey
+func uploadResults(g *errgroup.Group, key string, fileType extract.FileType, table *extract.Table, words []box.Box, urls html.URLs) error {
+	csvBody, err := csv.FromTable(table, csv.Default)
+	if err != nil {
+		return fmt.Errorf("failed to convert to csv: %w", err)
+	}
+	csvBytes := []byte(csvBody)
+	detailJSON, err := json.Marshal(detail.FromTable(table, words))
+	if err != nil {
+		return fmt.Errorf("failed to convert to detailed json: %w", err)
+	}
+	htmlBytes := html.FromTable(table, fileType, urls)
+
+	g.Go(func() error {
+		startUpload := time.Now()
+		if err := s3.UploadCSV(key, csvBytes); err != nil {
+			return err
+		}
+		log.Printf("s3 csv %s", time.Since(startUpload).String())
+		return nil
+	})
+	g.Go(func() error {
+		startUpload := time.Now()
+		if err := s3.UploadJSON(key, detailJSON); err != nil {
+			return err
+		}
+		log.Printf("s3 json %s", time.Since(startUpload).String())
+		return nil
+	})
+	g.Go(func() error {
+		startUpload := time.Now()
+		if err := s3.UploadHTML(key, htmlBytes); err != nil {
+			return err
+		}
+		log.Printf("s3 html %s", time.Since(startUpload).String())
+		return nil
+	})
+	return nil
 }
 
 func getAPIKey(decodedBodyBytes []byte, contentTypeHeader string, apiKeyHeader string) (string, error) {
@@ -419,28 +1115,25 @@ func getFile(decodedBodyBytes []byte, contentTypeHeader string) (*extract.File,
 // determineResponseMediaType determines what media type to return by looking at the Accept HTTP header
 // The header is on the form accept: text/html, application/xhtml+xml, application/xml;q=0.9
 // where the content types are listed in preferred order.
-func determineResponseMediaType(acceptResponseHeader string) (string, error) {
+// The parameters of the media type are returned too, e.g. delimiter in text/csv; delimiter=semicolon.
+func determineResponseMediaType(acceptResponseHeader string) (string, map[string]string, error) {
 	log.Printf("Accept: '%s'", acceptResponseHeader)
 	if acceptResponseHeader == "" {
-		return "application/json", nil
+		return "application/json", nil, nil
 	}
 	acceptResponseTypes := strings.Split(acceptResponseHeader, ",")
 	for _, e := range acceptResponseTypes {
-		mediaType, _, err := mime.ParseMediaType(e)
+		mediaType, params, err := mime.ParseMediaType(e)
 		if err != nil {
-			return "", fmt.Errorf("unable to parse media type '%s' in Accept header: %w", e, err)
-		}
-		if mediaType == "text/html" {
-			return mediaType, nil
-		}
-		if mediaType == "application/json" {
-			return mediaType, nil
+			return "", nil, fmt.Errorf("unable to parse media type '%s' in Accept header: %w", e, err)
 		}
-		if mediaType == "text/csv" {
-			return mediaType, nil
+		switch mediaType {
+		case "text/html", "application/json", csv.MediaType, csv.TSVMediaType, xlsx.MediaType,
+			markdown.MediaType, plaintext.MediaType, detail.MediaType, records.NDJSONMediaType, sql.MediaType, latex.MediaType:
+			return mediaType, params, nil
 		}
 	}
-	return "application/json", nil
+	return "application/json", nil, nil
 }
 
 // TODO: Store the detected table duh!
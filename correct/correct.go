@@ -0,0 +1,168 @@
+package correct
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/schema"
+)
+
+// Substitution is a correction of the text in a cell
+type Substitution struct {
+	Row    int    `json:"row"`
+	Column int    `json:"column"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// confusions are characters that OCR often reads instead of digits
+var confusions = map[rune]rune{
+	'O': '0',
+	'o': '0',
+	'D': '0',
+	'Q': '0',
+	'l': '1',
+	'I': '1',
+	'i': '1',
+	'|': '1',
+	'!': '1',
+	'Z': '2',
+	'z': '2',
+	'S': '5',
+	's': '5',
+	'G': '6',
+	'b': '6',
+	'T': '7',
+	'B': '8',
+	'g': '9',
+	'q': '9',
+}
+
+// digitGroupsRegexp matches numbers split into several words at the thousands, e.g. "1 234 567,89"
+var digitGroupsRegexp = regexp.MustCompile(`^([-+(]?\D{0,3}\d{1,3})( \d{3})+([.,]\d+)?(\D{0,3})$`)
+
+// Numeric corrects characters that OCR confused for digits, and rejoins numbers
+// split into several words, in the columns of the rows that are numeric.
+// A column is numeric if at least half of its cells are numbers in the locale,
+// and it's inferred to be a number type after the correction.
+// The row and column of the substitutions are indices into rows.
+func Numeric(rows [][]string, l normalize.Locale) ([][]string, []Substitution) {
+	width := 0
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	corrected := make([][]string, len(rows))
+	for i, row := range rows {
+		corrected[i] = append([]string{}, row...)
+	}
+	substitutions := make([]Substitution, 0)
+	for j := 0; j < width; j++ {
+		numbers := 0
+		nonEmpty := 0
+		candidates := make([]Substitution, 0)
+		for i, row := range rows {
+			if j >= len(row) || strings.TrimSpace(row[j]) == "" {
+				continue
+			}
+			nonEmpty++
+			if _, ok := normalize.Number(strings.TrimSpace(row[j]), l); ok {
+				numbers++
+				continue
+			}
+			if to, ok := Cell(row[j], l); ok {
+				candidates = append(candidates, Substitution{Row: i, Column: j, From: row[j], To: to})
+			}
+		}
+		if len(candidates) == 0 || 2*numbers < nonEmpty {
+			continue
+		}
+
+		column := make([][]string, len(rows))
+		for i, row := range rows {
+			column[i] = []string{""}
+			if j < len(row) {
+				column[i][0] = normalize.Value(row[j], l)
+			}
+		}
+		for _, c := range candidates {
+			column[c.Row][0] = normalize.Value(c.To, l)
+		}
+		if !schema.Infer(nil, column)[0].Type.Numeric() {
+			continue
+		}
+		for _, c := range candidates {
+			corrected[c.Row][c.Column] = c.To
+			substitutions = append(substitutions, c)
+		}
+	}
+	return corrected, substitutions
+}
+
+// Cell corrects the text in a cell that should be a number.
+// It returns false if the text isn't a number after the correction.
+func Cell(s string, l normalize.Locale) (string, bool) {
+	trimmed := strings.TrimSpace(s)
+	if digitGroupsRegexp.MatchString(trimmed) {
+		trimmed = strings.ReplaceAll(trimmed, " ", "")
+	}
+	if _, ok := normalize.Number(trimmed, l); ok {
+		return trimmed, trimmed != s
+	}
+
+	words := strings.Fields(trimmed)
+	for k, word := range words {
+		words[k] = digits(word)
+	}
+	fixed := strings.Join(words, " ")
+	if digitGroupsRegexp.MatchString(fixed) {
+		fixed = strings.ReplaceAll(fixed, " ", "")
+	}
+	if _, ok := normalize.Number(fixed, l); !ok {
+		return "", false
+	}
+	return fixed, true
+}
+
+// numberShapeRegexp matches a number with thousands or decimal separators, e.g. "1,234.50"
+var numberShapeRegexp = regexp.MustCompile(`^(\d{1,3}([.,'’]\d{3})+([.,]\d+)?|\d+[.,]\d+)$`)
+
+// digits replaces the characters in the word that OCR confused for digits.
+// A character is replaced if it's between digits, e.g. "1O5", or if the word has a digit and
+// is shaped like a number with it as a digit, e.g. "l,234". Words such as "NOK", "1990s",
+// "12b" or "Q3" are kept.
+func digits(word string) string {
+	runes := []rune(word)
+	shape := []rune(strings.Trim(word, "$€£¥%()+-"))
+	for k, r := range shape {
+		if _, ok := confusions[r]; ok {
+			shape[k] = '0'
+		}
+	}
+	shaped := strings.ContainsAny(word, "0123456789") && numberShapeRegexp.MatchString(string(shape))
+	for start := 0; start < len(runes); start++ {
+		if _, ok := confusions[runes[start]]; !ok {
+			continue
+		}
+		// the run of confused characters from start to end
+		end := start
+		for end < len(runes) {
+			if _, ok := confusions[runes[end]]; !ok {
+				break
+			}
+			end++
+		}
+		before := start > 0 && unicode.IsDigit(runes[start-1])
+		after := end < len(runes) && unicode.IsDigit(runes[end])
+		if shaped || (before && after) {
+			for k := start; k < end; k++ {
+				runes[k] = confusions[runes[k]]
+			}
+		}
+		start = end
+	}
+	return string(runes)
+}
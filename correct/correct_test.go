@@ -0,0 +1,35 @@
+package correct
+
+import (
+	"testing"
+
+	"github.com/vegarsti/extract/normalize"
+)
+
+func TestCell(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+		ok   bool
+	}{
+		{"1234", "1234", false},
+		{"1O5", "105", true},
+		{"1OO5", "1005", true},
+		{"l,234", "1,234", true},
+		{"1,234.5O", "1,234.50", true},
+		{"$l,234", "$1,234", true},
+		{"1 234 567", "1234567", true},
+		{"NOK", "", false},
+		{"TBD", "", false},
+		{"S.OO", "", false},
+		{"1990s", "", false},
+		{"12b", "", false},
+		{"Q3", "", false},
+	}
+	for _, test := range tests {
+		got, ok := Cell(test.text, normalize.US)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Cell(%q) = %q, %v, want %q, %v", test.text, got, ok, test.want, test.ok)
+		}
+	}
+}
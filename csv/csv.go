@@ -3,14 +3,148 @@ package csv
 import (
 	"bytes"
 	"encoding/csv"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vegarsti/extract"
 )
 
-func FromTable(table [][]string) string {
+// MediaType of CSV, and of TSV, which is CSV with tab as delimiter
+const MediaType = "text/csv"
+const TSVMediaType = "text/tab-separated-values"
+
+// Dialect is how the CSV is written
+type Dialect struct {
+	// Delimiter between fields
+	Delimiter rune
+	// CRLF ends lines with \r\n instead of \n
+	CRLF bool
+	// BOM starts the CSV with a UTF-8 byte order mark, which Excel needs to read it as UTF-8
+	BOM bool
+	// QuoteAll quotes every field, not only those with a delimiter, quote or newline
+	QuoteAll bool
+	// Safe prefixes fields that a spreadsheet would run as a formula with ',
+	// i.e. those that start with =, +, -, @, tab or carriage return and aren't numbers
+	Safe bool
+}
+
+// Default is comma separated, with lines ending in \n
+var Default = Dialect{Delimiter: ','}
+
+// TSV is tab separated
+var TSV = Dialect{Delimiter: '\t'}
+
+var delimiters = map[string]rune{
+	"comma":     ',',
+	"semicolon": ';',
+	"tab":       '\t',
+	"pipe":      '|',
+}
+
+// ParseDialect parses the parameters of the dialect, starting from d:
+// delimiter (comma, semicolon, tab, pipe or a single character), and crlf, bom, quote_all and safe,
+// which are either true or false
+func ParseDialect(d Dialect, params map[string]string) (Dialect, error) {
+	if v, ok := params["delimiter"]; ok {
+		delimiter, ok := delimiters[strings.ToLower(v)]
+		if !ok {
+			r, size := utf8.DecodeRuneInString(v)
+			if size != len(v) || !validDelimiter(r) {
+				return Dialect{}, fmt.Errorf("invalid value for delimiter: '%s', must be comma, semicolon, tab, pipe or a single character", v)
+			}
+			delimiter = r
+		}
+		d.Delimiter = delimiter
+	}
+	flags := []struct {
+		name  string
+		value *bool
+	}{
+		{"crlf", &d.CRLF},
+		{"bom", &d.BOM},
+		{"quote_all", &d.QuoteAll},
+		{"safe", &d.Safe},
+	}
+	for _, flag := range flags {
+		v, ok := params[flag.name]
+		if !ok {
+			continue
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return Dialect{}, fmt.Errorf("invalid value for %s: '%s', must be either true or false", flag.name, v)
+		}
+		*flag.value = b
+	}
+	return d, nil
+}
+
+// validDelimiter is the same check as encoding/csv does
+func validDelimiter(r rune) bool {
+	return r != 0 && r != '"' && r != '\r' && r != '\n' && utf8.ValidRune(r) && r != utf8.RuneError
+}
+
+// FromTable writes the table as CSV in the dialect, with the header rows flattened into one
+func FromTable(table *extract.Table, d Dialect) (string, error) {
 	s := &bytes.Buffer{}
+	if d.BOM {
+		s.WriteString("\ufeff")
+	}
+	rows := table.Flattened()
+	if d.Safe {
+		rows = safe(rows)
+	}
+	if d.QuoteAll {
+		// encoding/csv only quotes fields when it has to
+		lineEnding := "\n"
+		if d.CRLF {
+			lineEnding = "\r\n"
+		}
+		for _, row := range rows {
+			fields := make([]string, len(row))
+			for j, field := range row {
+				field = strings.ReplaceAll(field, `"`, `""`)
+				// line breaks in fields are written like the ends of lines, as encoding/csv does
+				if d.CRLF {
+					field = strings.ReplaceAll(strings.ReplaceAll(field, "\r\n", "\n"), "\n", "\r\n")
+				}
+				fields[j] = `"` + field + `"`
+			}
+			s.WriteString(strings.Join(fields, string(d.Delimiter)) + lineEnding)
+		}
+		return s.String(), nil
+	}
 	writer := csv.NewWriter(s)
-	for _, row := range table {
-		writer.Write(row)
+	writer.Comma = d.Delimiter
+	writer.UseCRLF = d.CRLF
+	for _, row := range rows {
+		if err := writer.Write(row); err != nil {
+			return "", fmt.Errorf("write csv: %w", err)
+		}
 	}
 	writer.Flush()
-	return s.String()
+	if err := writer.Error(); err != nil {
+		return "", fmt.Errorf("write csv: %w", err)
+	}
+	return s.String(), nil
+}
+
+var numberRegexp = regexp.MustCompile(`^[-+]?[\d.,' ]*\d[\d.,' ]*%?$`)
+
+// safe returns a copy of the rows where fields that would be formulas are prefixed with '
+func safe(rows [][]string) [][]string {
+	safeRows := make([][]string, len(rows))
+	for i, row := range rows {
+		safeRows[i] = make([]string, len(row))
+		for j, field := range row {
+			if field != "" && strings.ContainsAny(field[:1], "=+-@\t\r") && !numberRegexp.MatchString(field) {
+				field = "'" + field
+			}
+			safeRows[i][j] = field
+		}
+	}
+	return safeRows
 }
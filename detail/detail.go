@@ -0,0 +1,95 @@
+package detail
+
+import (
+	"math"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/geometry"
+)
+
+// MediaType of the detailed JSON
+const MediaType = "application/vnd.extract-table+json"
+
+// Word is a word found by OCR
+type Word struct {
+	Text       string        `json:"text"`
+	Rect       geometry.Rect `json:"rect"`
+	Confidence float64       `json:"confidence"`
+}
+
+// Cell is a cell with where it is in the table and in the document,
+// and the words it was made from
+type Cell struct {
+	Row    int `json:"row"`
+	Column int `json:"column"`
+	extract.Cell
+	Words []Word `json:"words"`
+}
+
+// Table is a table where every cell can be traced back to the document
+type Table struct {
+	Rows       [][]Cell `json:"rows"`
+	Columns    []string `json:"columns"`
+	HeaderRows int      `json:"header_rows"`
+	Pages      []int    `json:"pages"`
+	Direction  string   `json:"direction"`
+	Locale     string   `json:"locale"`
+}
+
+// FromTable returns the table with the words in each cell.
+// A word is in the cell in its row that is nearest its center,
+// so words in header rows spanning several columns are in one of them.
+// Cells that aren't in the document, such as a page column, have no words.
+func FromTable(table *extract.Table, words []box.Box) *Table {
+	detailed := &Table{
+		Rows:       make([][]Cell, len(table.Rows)),
+		Columns:    table.Columns,
+		HeaderRows: table.HeaderRows,
+		Pages:      table.Pages,
+		Direction:  table.Direction,
+		Locale:     table.Locale,
+	}
+	for i, row := range table.Rows {
+		detailed.Rows[i] = make([]Cell, len(row))
+		for j, cell := range row {
+			detailed.Rows[i][j] = Cell{Row: i, Column: j, Cell: cell, Words: make([]Word, 0)}
+		}
+	}
+	for _, w := range words {
+		i, j, ok := nearest(table, w)
+		if !ok {
+			continue
+		}
+		detailed.Rows[i][j].Words = append(detailed.Rows[i][j].Words, Word{
+			Text:       w.Content,
+			Rect:       w.Rect(),
+			Confidence: w.Confidence,
+		})
+	}
+	return detailed
+}
+
+// nearest returns the cell in the row of the word that is nearest its center
+func nearest(table *extract.Table, w box.Box) (int, int, bool) {
+	center := w.Rect().Center()
+	for i, row := range table.Rows {
+		found := false
+		nearestColumn := 0
+		nearestDistance := math.Inf(1)
+		for j, cell := range row {
+			if cell.Page != w.Page || cell.Rect.Empty() || center.Y < cell.Rect.Top || center.Y > cell.Rect.Bottom {
+				continue
+			}
+			if distance := cell.Rect.Distance(center.Rect()); distance < nearestDistance {
+				found = true
+				nearestColumn = j
+				nearestDistance = distance
+			}
+		}
+		if found {
+			return i, nearestColumn, true
+		}
+	}
+	return 0, 0, false
+}
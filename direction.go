@@ -0,0 +1,27 @@
+package extract
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vegarsti/extract/direction"
+)
+
+// Text joins the words in a cell in reading order:
+// from left to right, or from right to left if the text is in a right-to-left script.
+// The words should be on the same row.
+func Text(words []Word) string {
+	sorted := make([]Word, len(words))
+	copy(sorted, words)
+	sort.Stable(byXLeft(sorted))
+	texts := make([]string, len(sorted))
+	for i, w := range sorted {
+		texts[i] = w.Text
+	}
+	if direction.IsRTL(strings.Join(texts, "")) {
+		for i, j := 0, len(texts)-1; i < j; i, j = i+1, j-1 {
+			texts[i], texts[j] = texts[j], texts[i]
+		}
+	}
+	return strings.TrimSpace(strings.Join(texts, " "))
+}
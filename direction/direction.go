@@ -0,0 +1,34 @@
+package direction
+
+import (
+	"unicode"
+)
+
+// rtlScripts are the scripts that are written from right to left
+var rtlScripts = []*unicode.RangeTable{
+	unicode.Arabic,
+	unicode.Hebrew,
+	unicode.Syriac,
+	unicode.Thaana,
+	unicode.Nko,
+	unicode.Samaritan,
+	unicode.Mandaic,
+}
+
+// IsRTL is true if most of the letters in the text are in a script
+// that is written from right to left, such as Arabic or Hebrew
+func IsRTL(text string) bool {
+	rtl := 0
+	ltr := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if unicode.In(r, rtlScripts...) {
+			rtl++
+		} else {
+			ltr++
+		}
+	}
+	return rtl > ltr
+}
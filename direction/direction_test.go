@@ -0,0 +1,24 @@
+package direction
+
+import "testing"
+
+func TestIsRTL(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"", false},
+		{"Revenue", false},
+		{"1 234,56", false},
+		{"الإيرادات", true},
+		{"הכנסות", true},
+		{"שכר 2021", true},
+		{"Total الإيرادات", true},
+		{"Revenue (ש)", false},
+	}
+	for _, test := range tests {
+		if got := IsRTL(test.text); got != test.want {
+			t.Errorf("IsRTL(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
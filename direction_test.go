@@ -0,0 +1,32 @@
+package extract
+
+import (
+	"testing"
+
+	"github.com/vegarsti/extract/geometry"
+)
+
+// word returns a word on a row from left to right
+func word(text string, left, right float64) Word {
+	return NewWord(text, geometry.Rect{Left: left, Right: right, Top: 0.1, Bottom: 0.12})
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name  string
+		words []Word
+		want  string
+	}{
+		{"empty", nil, ""},
+		{"ltr", []Word{word("costs", 0.3, 0.4), word("Operating", 0.1, 0.25)}, "Operating costs"},
+		{"rtl", []Word{word("תפעול", 0.1, 0.2), word("הוצאות", 0.25, 0.4)}, "הוצאות תפעול"},
+		{"rtl with number", []Word{word("2021", 0.1, 0.15), word("שכר", 0.2, 0.3)}, "שכר 2021"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Text(test.words); got != test.want {
+				t.Errorf("Text() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
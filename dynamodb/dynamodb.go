@@ -1,12 +1,16 @@
 package dynamodb
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/vegarsti/extract"
 )
 
 func CreateTable(sess *session.Session) error {
@@ -35,36 +39,87 @@ func CreateTable(sess *session.Session) error {
 	return nil
 }
 
-func PutTable(checksum string, table []byte, boxesJSON []byte) error {
+// ErrNotFound is returned when there is no table stored by the checksum
+var ErrNotFound = errors.New("no table is stored by the checksum")
+
+// PutTable stores the table, the type of the file and the boxes the table was made from,
+// by the checksum of the file. A corrected table stored by the same checksum is kept.
+func PutTable(checksum string, fileType extract.FileType, table *extract.Table, boxesJSON []byte) error {
+	tableJSON, err := json.Marshal(table)
+	if err != nil {
+		return fmt.Errorf("failed to convert table to json: %w", err)
+	}
 	sess, err := session.NewSession()
 	if err != nil {
 		return fmt.Errorf("unable to create session: %w", err)
 	}
 	svc := dynamodb.New(sess)
-	putInput := &dynamodb.PutItemInput{
-		Item: map[string]*dynamodb.AttributeValue{
+	updateInput := &dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
 			"Checksum": {S: &checksum},
-			// Old: Used table detection directly, new uses custom algorithm
-			// "JSONTable": {B: table},
-			"JSONTableCustomDetection": {B: table},
-			"JSONBoxes":                {B: boxesJSON},
-			"Timestamp":                {S: aws.String(time.Now().Format(time.RFC3339))},
+		},
+		// Old: Used table detection directly, new uses custom algorithm
+		// "JSONTable": {B: table},
+		// Older items have the text of the table in JSONTableCustomDetection
+		UpdateExpression: aws.String("SET JSONRichTable = :table, JSONBoxes = :boxes, FileType = :fileType, #timestamp = :timestamp"),
+		// Timestamp is a reserved word
+		ExpressionAttributeNames: map[string]*string{"#timestamp": aws.String("Timestamp")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":table":     {B: tableJSON},
+			":boxes":     {B: boxesJSON},
+			":fileType":  {S: aws.String(string(fileType))},
+			":timestamp": {S: aws.String(time.Now().Format(time.RFC3339))},
 		},
 		TableName: aws.String("Tables"),
 	}
-	if _, err := svc.PutItem(putInput); err != nil {
-		return fmt.Errorf("put item: %w", err)
+	if _, err := svc.UpdateItem(updateInput); err != nil {
+		return fmt.Errorf("update item: %w", err)
 	}
 	return nil
 }
 
-func GetTable(checksum string) ([]byte, error) {
+// PutCorrectedTable stores a table that has been corrected by hand next to the table that was extracted,
+// so the two can be compared. It returns ErrNotFound if no table is stored by the checksum.
+func PutCorrectedTable(checksum string, table *extract.Table) error {
+	tableJSON, err := json.Marshal(table)
+	if err != nil {
+		return fmt.Errorf("failed to convert table to json: %w", err)
+	}
+	sess, err := session.NewSession()
+	if err != nil {
+		return fmt.Errorf("unable to create session: %w", err)
+	}
+	svc := dynamodb.New(sess)
+	updateInput := &dynamodb.UpdateItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"Checksum": {S: &checksum},
+		},
+		UpdateExpression:    aws.String("SET JSONCorrectedTable = :table, CorrectedTimestamp = :timestamp"),
+		ConditionExpression: aws.String("attribute_exists(Checksum)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":table":     {B: tableJSON},
+			":timestamp": {S: aws.String(time.Now().Format(time.RFC3339))},
+		},
+		TableName: aws.String("Tables"),
+	}
+	if _, err := svc.UpdateItem(updateInput); err != nil {
+		var aerr awserr.Error
+		if errors.As(err, &aerr) && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			return ErrNotFound
+		}
+		return fmt.Errorf("update item: %w", err)
+	}
+	return nil
+}
+
+// GetCorrectedTable returns the corrected table stored by the checksum of the file, or nil if there is none
+func GetCorrectedTable(checksum string) (*extract.Table, error) {
 	sess, err := session.NewSession()
 	if err != nil {
 		return nil, fmt.Errorf("unable to create session: %w", err)
 	}
 	svc := dynamodb.New(sess)
-	projection := "JSONTable,JSONTableCustomDetection"
+	projection := "JSONCorrectedTable"
 	getInput := &dynamodb.GetItemInput{
 		Key: map[string]*dynamodb.AttributeValue{
 			"Checksum": {S: &checksum},
@@ -76,17 +131,90 @@ func GetTable(checksum string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("get item: %w", err)
 	}
-	var table *dynamodb.AttributeValue
+	corrected, ok := output.Item["JSONCorrectedTable"]
+	if !ok {
+		return nil, nil
+	}
+	var table extract.Table
+	if err := json.Unmarshal(corrected.B, &table); err != nil {
+		return nil, fmt.Errorf("failed to convert table from json: %w", err)
+	}
+	return &table, nil
+}
+
+// GetSource returns the type of the file stored by the checksum and the boxes its table was made from.
+// The type is empty for older items, and the boxes are nil if there are none.
+func GetSource(checksum string) (extract.FileType, []byte, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create session: %w", err)
+	}
+	svc := dynamodb.New(sess)
+	projection := "FileType,JSONBoxes"
+	getInput := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"Checksum": {S: &checksum},
+		},
+		ProjectionExpression: &projection,
+		TableName:            aws.String("Tables"),
+	}
+	output, err := svc.GetItem(getInput)
+	if err != nil {
+		return "", nil, fmt.Errorf("get item: %w", err)
+	}
+	var fileType extract.FileType
+	if v, ok := output.Item["FileType"]; ok && v.S != nil {
+		fileType = extract.FileType(*v.S)
+	}
+	var boxesJSON []byte
+	if v, ok := output.Item["JSONBoxes"]; ok {
+		boxesJSON = v.B
+	}
+	return fileType, boxesJSON, nil
+}
+
+// GetTable returns the table stored by the checksum of the file, or nil if there is none.
+// This is the table as it was extracted, see GetCorrectedTable for the table corrected by hand.
+func GetTable(checksum string) (*extract.Table, error) {
+	sess, err := session.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create session: %w", err)
+	}
+	svc := dynamodb.New(sess)
+	projection := "JSONTable,JSONTableCustomDetection,JSONRichTable"
+	getInput := &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"Checksum": {S: &checksum},
+		},
+		ProjectionExpression: &projection,
+		TableName:            aws.String("Tables"),
+	}
+	output, err := svc.GetItem(getInput)
+	if err != nil {
+		return nil, fmt.Errorf("get item: %w", err)
+	}
+	if rich, ok := output.Item["JSONRichTable"]; ok {
+		var table extract.Table
+		if err := json.Unmarshal(rich.B, &table); err != nil {
+			return nil, fmt.Errorf("failed to convert table from json: %w", err)
+		}
+		return &table, nil
+	}
+	// older items only have the text
+	var text *dynamodb.AttributeValue
 	var ok bool
-	table, ok = output.Item["JSONTable"]
+	text, ok = output.Item["JSONTable"]
 	if !ok {
-		table, ok = output.Item["JSONTableCustomDetection"]
+		text, ok = output.Item["JSONTableCustomDetection"]
 		if !ok {
 			return nil, nil
 		}
-		return table.B, nil
 	}
-	return table.B, nil
+	var rows [][]string
+	if err := json.Unmarshal(text.B, &rows); err != nil {
+		return nil, fmt.Errorf("failed to convert table from json: %w", err)
+	}
+	return extract.NewTable(rows), nil
 }
 
 func VerifyAPIKey(key string) (bool, error) {
@@ -1,7 +1,10 @@
 package extract
 
 import (
+	"math"
 	"sort"
+
+	"github.com/vegarsti/extract/geometry"
 )
 
 type Word struct {
@@ -12,6 +15,16 @@ type Word struct {
 	BottomY float64
 }
 
+// NewWord returns a word with the text covering the rectangle
+func NewWord(text string, r geometry.Rect) Word {
+	return Word{Text: text, LeftX: r.Left, RightX: r.Right, TopY: r.Top, BottomY: r.Bottom}
+}
+
+// Rect is the rectangle covered by the word
+func (w Word) Rect() geometry.Rect {
+	return geometry.Rect{Left: w.LeftX, Right: w.RightX, Top: w.TopY, Bottom: w.BottomY}
+}
+
 type byXLeft []Word
 
 func (s byXLeft) Len() int {
@@ -24,30 +37,6 @@ func (s byXLeft) Less(i, j int) bool {
 	return s[i].LeftX < s[j].LeftX
 }
 
-type byRow []Word
-
-func (s byRow) Len() int {
-	return len(s)
-}
-func (s byRow) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-func (s byRow) Less(i, j int) bool {
-	// sort boxes by row, then by x
-	// find row by checking if the bottom y is above the top y.
-	// within a row, use xLeft
-
-	if s[i].BottomY < s[j].TopY {
-		return true // i is on row before j
-	}
-	if s[i].TopY > s[j].BottomY {
-		return false // j is on row before i
-	}
-
-	// same row, so compare x
-	return s[i].LeftX < s[j].LeftX
-}
-
 type BySize [][2]float64
 
 func (s BySize) Len() int {
@@ -88,7 +77,7 @@ func FindSplits(words []Word) []float64 {
 }
 
 func SplitRowBoxesMidpoint(words []Word, xs []float64) [][]Word {
-	midpoint := func(word Word) float64 { return word.LeftX + (word.RightX-word.LeftX)/2 }
+	midpoint := func(word Word) float64 { return word.Rect().Center().X }
 	partitions := SplitRowBoxesFunc(words, xs, midpoint)
 	return partitions
 }
@@ -99,6 +88,117 @@ func SplitRowBoxesEdge(words []Word, xs []float64) [][]Word {
 	return partitions
 }
 
+// Alignment is how the text in a column is aligned
+type Alignment string
+
+const AlignLeft = Alignment("left")
+const AlignRight = Alignment("right")
+const AlignCenter = Alignment("center")
+
+// DetectAlignment returns the alignment of each column between the splits xs.
+// The words are first put in columns by their midpoint, and the alignment of a column is
+// the edge of its cells that varies the least: the left edge, the right edge or the center.
+func DetectAlignment(rows [][]Word, xs []float64) []Alignment {
+	lefts := make([][]float64, len(xs)+1)
+	rights := make([][]float64, len(xs)+1)
+	centers := make([][]float64, len(xs)+1)
+	for _, row := range rows {
+		for j, cell := range SplitRowBoxesMidpoint(row, xs) {
+			if len(cell) == 0 {
+				continue
+			}
+			left := cell[0].LeftX
+			right := cell[0].RightX
+			for _, w := range cell[1:] {
+				left = math.Min(left, w.LeftX)
+				right = math.Max(right, w.RightX)
+			}
+			lefts[j] = append(lefts[j], left)
+			rights[j] = append(rights[j], right)
+			centers[j] = append(centers[j], left+(right-left)/2)
+		}
+	}
+	alignments := make([]Alignment, len(xs)+1)
+	for j := range alignments {
+		alignments[j] = AlignLeft
+		least := variance(lefts[j])
+		if v := variance(rights[j]); v < least {
+			alignments[j] = AlignRight
+			least = v
+		}
+		if v := variance(centers[j]); v < least {
+			alignments[j] = AlignCenter
+		}
+	}
+	return alignments
+}
+
+func variance(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	v := 0.0
+	for _, x := range xs {
+		v += (x - mean) * (x - mean)
+	}
+	return v / float64(len(xs))
+}
+
+// SplitRowBoxesAligned returns a split function that puts each word in the column where
+// the edge matching the alignment of the column is inside the column: the right edge for
+// right-aligned columns, e.g. numbers, the left edge for left-aligned columns and the
+// midpoint for centered columns. If several columns match, the word is put in the one where
+// the edge is furthest from the splits, and if none match, the midpoint is used.
+// alignments are the alignments of the columns, see DetectAlignment.
+func SplitRowBoxesAligned(alignments []Alignment) func([]Word, []float64) [][]Word {
+	return func(words []Word, xs []float64) [][]Word {
+		sort.Sort(byXLeft(words))
+		partitions := make([][]Word, len(xs)+1)
+		for i := range partitions {
+			partitions[i] = make([]Word, 0)
+		}
+		for _, word := range words {
+			best := -1
+			bestMargin := 0.0
+			for j := range partitions {
+				left := math.Inf(-1)
+				if j > 0 {
+					left = xs[j-1]
+				}
+				right := math.Inf(1)
+				if j < len(xs) {
+					right = xs[j]
+				}
+				anchor := word.Rect().Center().X
+				if j < len(alignments) && alignments[j] == AlignLeft {
+					anchor = word.LeftX
+				}
+				if j < len(alignments) && alignments[j] == AlignRight {
+					anchor = word.RightX
+				}
+				if anchor < left || anchor > right {
+					continue
+				}
+				margin := math.Min(anchor-left, right-anchor)
+				if best == -1 || margin > bestMargin {
+					best = j
+					bestMargin = margin
+				}
+			}
+			if best == -1 {
+				best = sort.SearchFloat64s(xs, word.Rect().Center().X)
+			}
+			partitions[best] = append(partitions[best], word)
+		}
+		return partitions
+	}
+}
+
 func SplitRowBoxesFunc(words []Word, xs []float64, f func(Word) float64) [][]Word {
 	sort.Sort(byXLeft(words))
 	partitions := make([][]Word, len(xs)+1)
@@ -108,7 +208,8 @@ func SplitRowBoxesFunc(words []Word, xs []float64, f func(Word) float64) [][]Wor
 
 	i := 0
 	for _, word := range words {
-		if i < len(xs) && f(word) > xs[i] {
+		// skip past empty columns
+		for i < len(xs) && f(word) > xs[i] {
 			i++
 		}
 		partitions[i] = append(partitions[i], word)
@@ -116,27 +217,87 @@ func SplitRowBoxesFunc(words []Word, xs []float64, f func(Word) float64) [][]Wor
 	return partitions
 }
 
-// words need to be sorted by row order,
-// assume one row has max(yBottom) < min(yMax) other row
+// PartitionIntoRows groups the words into rows by their vertical center,
+// see geometry.ClusterRows. The words in each row are sorted by LeftX.
 func PartitionIntoRows(words []Word) [][]Word {
-	// sort by row
-	sort.Sort(byRow(words))
-
-	partitions := make([][]Word, 0)
-	firstRow := make([]Word, 0)
-	firstRow = append(firstRow, words[0])
-	partitions = append(partitions, firstRow)
-	i := 0
-	prevX := float64(0)
-	for _, w := range words[1:] {
-		// if new row
-		if w.LeftX < prevX {
-			i++
-			newRow := make([]Word, 0)
-			partitions = append(partitions, newRow)
+	clusters := geometry.ClusterRows(
+		len(words),
+		func(i int) float64 { return words[i].TopY },
+		func(i int) float64 { return words[i].BottomY },
+	)
+	partitions := make([][]Word, len(clusters))
+	for i, cluster := range clusters {
+		partitions[i] = make([]Word, len(cluster))
+		for j, k := range cluster {
+			partitions[i][j] = words[k]
 		}
-		partitions[i] = append(partitions[i], w)
-		prevX = w.LeftX
+		sort.Stable(byXLeft(partitions[i]))
 	}
 	return partitions
 }
+
+// DefaultMinSupport is the default fraction of rows that may cover a gutter between columns,
+// e.g. with a long word that bridges it
+const DefaultMinSupport = 0.1
+
+// FindSplitsProjection finds the splits between columns from the horizontal projection
+// profile of the rows: for every x, the number of rows with a word covering it.
+// Gutters are ranges of x covered by at most minSupport of the rows, so a single long
+// word bridging a gutter doesn't merge the columns. The splits are in the middle of the gutters.
+func FindSplitsProjection(rows [][]Word, minSupport float64) []float64 {
+	type event struct {
+		x     float64
+		delta int
+	}
+	events := make([]event, 0)
+	for _, row := range rows {
+		// the x ranges covered by the row, so overlapping words only count once
+		covered := make([][2]float64, 0)
+		sorted := append([]Word{}, row...)
+		sort.Sort(byXLeft(sorted))
+		for _, w := range sorted {
+			if n := len(covered); n > 0 && w.LeftX <= covered[n-1][1] {
+				if w.RightX > covered[n-1][1] {
+					covered[n-1][1] = w.RightX
+				}
+				continue
+			}
+			covered = append(covered, [2]float64{w.LeftX, w.RightX})
+		}
+		for _, c := range covered {
+			events = append(events, event{c[0], 1}, event{c[1], -1})
+		}
+	}
+	if len(events) == 0 {
+		return []float64{}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].x == events[j].x {
+			// ranges that touch aren't a gutter
+			return events[i].delta > events[j].delta
+		}
+		return events[i].x < events[j].x
+	})
+
+	threshold := int(minSupport * float64(len(rows)))
+	splits := make([]float64, 0)
+	coverage := 0
+	gutterStart := -1.0
+	for i, e := range events {
+		coverage += e.delta
+		if i == len(events)-1 {
+			break
+		}
+		inGutter := coverage <= threshold
+		if inGutter && gutterStart < 0 {
+			gutterStart = e.x
+		}
+		if !inGutter && gutterStart >= 0 {
+			if gutterStart > events[0].x {
+				splits = append(splits, gutterStart+(e.x-gutterStart)/2)
+			}
+			gutterStart = -1
+		}
+	}
+	return splits
+}
@@ -0,0 +1,202 @@
+package geometry
+
+import (
+	"math"
+	"sort"
+)
+
+// Point is a position on a page
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Rect is the rectangle with no area at the point
+func (p Point) Rect() Rect {
+	return Rect{Left: p.X, Right: p.X, Top: p.Y, Bottom: p.Y}
+}
+
+// Rotate the point by angle radians clockwise around the point o.
+// Clockwise since y increases downwards.
+func (p Point) Rotate(angle float64, o Point) Point {
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	x, y := p.X-o.X, p.Y-o.Y
+	return Point{X: o.X + x*cos - y*sin, Y: o.Y + x*sin + y*cos}
+}
+
+// Rect is a rectangle on a page, with edges parallel to the edges of the page.
+// Coordinates are relative to the size of the page, from 0 to 1,
+// with y increasing downwards, as in Textract:
+//
+//	top left is {X: 0, Y: 0}, bottom right is {X: 1, Y: 1}
+type Rect struct {
+	Left   float64 `json:"left"`
+	Right  float64 `json:"right"`
+	Top    float64 `json:"top"`
+	Bottom float64 `json:"bottom"`
+}
+
+// FromSize returns the rectangle with its top left corner at left and top
+func FromSize(left, top, width, height float64) Rect {
+	return Rect{Left: left, Right: left + width, Top: top, Bottom: top + height}
+}
+
+// FromPoints returns the smallest rectangle containing the points, e.g. of a polygon
+func FromPoints(points []Point) Rect {
+	if len(points) == 0 {
+		return Rect{}
+	}
+	r := Rect{Left: points[0].X, Right: points[0].X, Top: points[0].Y, Bottom: points[0].Y}
+	for _, p := range points[1:] {
+		r.Left = math.Min(r.Left, p.X)
+		r.Right = math.Max(r.Right, p.X)
+		r.Top = math.Min(r.Top, p.Y)
+		r.Bottom = math.Max(r.Bottom, p.Y)
+	}
+	return r
+}
+
+func (r Rect) Width() float64 {
+	return r.Right - r.Left
+}
+
+func (r Rect) Height() float64 {
+	return r.Bottom - r.Top
+}
+
+func (r Rect) Area() float64 {
+	if r.Empty() {
+		return 0
+	}
+	return r.Width() * r.Height()
+}
+
+// Empty is true if the rectangle has no area
+func (r Rect) Empty() bool {
+	return r.Right <= r.Left || r.Bottom <= r.Top
+}
+
+func (r Rect) Center() Point {
+	return Point{X: r.Left + r.Width()/2, Y: r.Top + r.Height()/2}
+}
+
+// Intersect returns the rectangle covered by both rectangles,
+// which is empty if they don't overlap
+func (r Rect) Intersect(o Rect) Rect {
+	i := Rect{
+		Left:   math.Max(r.Left, o.Left),
+		Right:  math.Min(r.Right, o.Right),
+		Top:    math.Max(r.Top, o.Top),
+		Bottom: math.Min(r.Bottom, o.Bottom),
+	}
+	if i.Empty() {
+		return Rect{}
+	}
+	return i
+}
+
+// Union returns the smallest rectangle containing both rectangles
+func (r Rect) Union(o Rect) Rect {
+	return Rect{
+		Left:   math.Min(r.Left, o.Left),
+		Right:  math.Max(r.Right, o.Right),
+		Top:    math.Min(r.Top, o.Top),
+		Bottom: math.Max(r.Bottom, o.Bottom),
+	}
+}
+
+// OverlapRatio is the share of the area of r that is covered by o, from 0 to 1
+func (r Rect) OverlapRatio(o Rect) float64 {
+	area := r.Area()
+	if area == 0 {
+		return 0
+	}
+	return r.Intersect(o).Area() / area
+}
+
+// XOverlap is true if the x coordinates of the rectangles overlap, touching included
+func (r Rect) XOverlap(o Rect) bool {
+	return r.Right >= o.Left && r.Left <= o.Right
+}
+
+// YOverlap is true if the y coordinates of the rectangles overlap, touching included
+func (r Rect) YOverlap(o Rect) bool {
+	return r.Bottom >= o.Top && r.Top <= o.Bottom
+}
+
+// Distance is the shortest distance between the edges of the rectangles,
+// which is 0 if they overlap
+func (r Rect) Distance(o Rect) float64 {
+	dx := math.Max(0, math.Max(o.Left-r.Right, r.Left-o.Right))
+	dy := math.Max(0, math.Max(o.Top-r.Bottom, r.Top-o.Bottom))
+	return math.Hypot(dx, dy)
+}
+
+// Contains is true if the point is inside the rectangle,
+// or at most tolerance outside any of its edges
+func (r Rect) Contains(p Point, tolerance float64) bool {
+	return p.X >= r.Left-tolerance && p.X <= r.Right+tolerance && p.Y >= r.Top-tolerance && p.Y <= r.Bottom+tolerance
+}
+
+// ContainsRect is true if o is inside the rectangle,
+// or at most tolerance outside any of its edges
+func (r Rect) ContainsRect(o Rect, tolerance float64) bool {
+	return o.Left >= r.Left-tolerance && o.Right <= r.Right+tolerance && o.Top >= r.Top-tolerance && o.Bottom <= r.Bottom+tolerance
+}
+
+// Rotate the rectangle by angle radians clockwise around the point o,
+// and return the smallest rectangle containing the rotated corners,
+// e.g. to correct for a skewed scan
+func (r Rect) Rotate(angle float64, o Point) Rect {
+	corners := []Point{
+		{X: r.Left, Y: r.Top},
+		{X: r.Right, Y: r.Top},
+		{X: r.Left, Y: r.Bottom},
+		{X: r.Right, Y: r.Bottom},
+	}
+	for i := range corners {
+		corners[i] = corners[i].Rotate(angle, o)
+	}
+	return FromPoints(corners)
+}
+
+// Scale the rectangle from relative coordinates to e.g. pixels in an image of width and height
+func (r Rect) Scale(width, height float64) Rect {
+	return Rect{Left: r.Left * width, Right: r.Right * width, Top: r.Top * height, Bottom: r.Bottom * height}
+}
+
+// ClusterRows groups n items with the given top and bottom y coordinates into rows,
+// by clustering their vertical centers (close to the baseline of the text).
+// An item starts a new row if its center is further below the average center of the
+// current row than half the median height of the items, so superscripts and slightly
+// slanted rows stay in one row.
+// Returns the indices of the items in each row, with rows ordered from top to bottom.
+func ClusterRows(n int, top func(int) float64, bottom func(int) float64) [][]int {
+	if n == 0 {
+		return [][]int{}
+	}
+	indices := make([]int, n)
+	heights := make([]float64, n)
+	for i := range indices {
+		indices[i] = i
+		heights[i] = bottom(i) - top(i)
+	}
+	center := func(i int) float64 { return top(i) + (bottom(i)-top(i))/2 }
+	sort.SliceStable(indices, func(a, b int) bool { return center(indices[a]) < center(indices[b]) })
+	sort.Float64s(heights)
+	tolerance := heights[n/2] / 2
+
+	rows := [][]int{{indices[0]}}
+	sum := center(indices[0])
+	for _, i := range indices[1:] {
+		row := rows[len(rows)-1]
+		if center(i)-sum/float64(len(row)) > tolerance {
+			rows = append(rows, []int{i})
+			sum = center(i)
+			continue
+		}
+		rows[len(rows)-1] = append(row, i)
+		sum += center(i)
+	}
+	return rows
+}
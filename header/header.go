@@ -0,0 +1,278 @@
+package header
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/box"
+)
+
+// Separator is put between a parent header and the header below it,
+// e.g. "2020 / Revenue"
+const Separator = " / "
+
+var numberRegexp = regexp.MustCompile(`^[-+(]?[$€£¥]?\s?\d[\d,.\s]*%?\)?$`)
+var yearRegexp = regexp.MustCompile(`^(19|20)\d\d$`)
+
+// isLabel is true if the text can be part of a header,
+// i.e. it's not a number, unless it looks like a year
+func isLabel(s string) bool {
+	return !numberRegexp.MatchString(s) || yearRegexp.MatchString(s)
+}
+
+func filled(row []box.Box) int {
+	n := 0
+	for _, cell := range row {
+		if cell.Content != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func isLabelRow(row []box.Box) bool {
+	for _, cell := range row {
+		if cell.Content != "" && !isLabel(cell.Content) {
+			return false
+		}
+	}
+	return filled(row) > 0
+}
+
+// stub is true if the first column has no label in the header row,
+// so its label may be in a parent row, e.g. "Name" next to "2020" above "Revenue | Cost"
+func stub(row []box.Box) bool {
+	return len(row) > 1 && row[0].Content == ""
+}
+
+// parentFilled is the number of labels in the parent row, except the label of the first column
+// if it's there instead of in the header row
+func parentFilled(parent []box.Box, row []box.Box) int {
+	n := filled(parent)
+	if stub(row) && len(parent) > 0 && parent[0].Content != "" {
+		n--
+	}
+	return n
+}
+
+// Detect returns the number of rows at the top of the table that make up the header,
+// or 0 if the table doesn't seem to have a header.
+// The last header row has a label in most columns, and the rows above it (the parent rows)
+// have labels in fewer columns, e.g. "2020" and "2021" above "Revenue | Cost | Revenue | Cost".
+// The label of the first column may be in a parent row.
+func Detect(rows [][]box.Box) int {
+	for d := box.MaxHeaderRows - 1; d >= 0; d-- {
+		// there must be at least one row of data below the header
+		if d >= len(rows)-1 {
+			continue
+		}
+		if !isLabelRow(rows[d]) || filled(rows[d]) < 2 {
+			continue
+		}
+		stacked := true
+		for p := 0; p < d; p++ {
+			if !isLabelRow(rows[p]) || parentFilled(rows[p], rows[d]) >= filled(rows[d]) {
+				stacked = false
+				break
+			}
+		}
+		if stacked {
+			return d + 1
+		}
+	}
+	return 0
+}
+
+// Flatten detects the header rows of the table and returns one name per column,
+// where the names of parent headers are prepended, e.g. "2020 / Revenue",
+// along with the number of header rows.
+// Which columns a parent header spans is decided by the x-extent of its words,
+// so words should be the word boxes the table was made from.
+// If words is nil, the extent of the cells is used instead.
+func Flatten(rows [][]box.Box, words []box.Box) ([]string, int) {
+	n := Detect(rows)
+	if n == 0 {
+		return nil, 0
+	}
+	columns := rows[n-1]
+	parts := make([][]string, len(columns))
+	// the label of the first column in a parent row names the column, and doesn't span others
+	first := 0
+	if stub(columns) {
+		first = 1
+	}
+	for p := 0; p < n-1; p++ {
+		if first == 1 && rows[p][0].Content != "" {
+			parts[0] = append(parts[0], rows[p][0].Content)
+		}
+		for j, label := range parentLabels(rows[p], columns[first:], words) {
+			if label != "" {
+				parts[first+j] = append(parts[first+j], label)
+			}
+		}
+	}
+	names := make([]string, len(columns))
+	for j, cell := range columns {
+		if cell.Content != "" {
+			parts[j] = append(parts[j], cell.Content)
+		}
+		names[j] = strings.Join(parts[j], Separator)
+	}
+	return names, n
+}
+
+// Columns returns one name per column from the header rows of a table that has been corrected by hand,
+// where the spans of the cells say which columns a parent header spans.
+func Columns(table *extract.Table) []string {
+	if table.HeaderRows == 0 || table.HeaderRows > len(table.Rows) {
+		return make([]string, 0)
+	}
+	width := table.Width()
+	parts := make([][]string, width)
+	covered := table.Covered()
+	for i, row := range table.Rows[:table.HeaderRows] {
+		for j, cell := range row {
+			text := strings.TrimSpace(cell.Text)
+			if covered[i][j] || text == "" {
+				continue
+			}
+			for k := j; k < j+cell.ColumnSpan && k < width; k++ {
+				parts[k] = append(parts[k], text)
+			}
+		}
+	}
+	names := make([]string, width)
+	for j := range names {
+		names[j] = strings.Join(parts[j], Separator)
+	}
+	return names
+}
+
+// phrases returns the text in the row as boxes of adjacent words
+func phrases(row []box.Box, words []box.Box) []box.Box {
+	if len(row) == 0 {
+		return nil
+	}
+	top := row[0].YTop
+	bottom := row[0].YBottom
+	inRow := make([]box.Box, 0)
+	heights := make([]float64, 0)
+	for _, w := range words {
+		if center := w.Rect().Center(); center.Y >= top && center.Y <= bottom {
+			inRow = append(inRow, w)
+			heights = append(heights, w.YBottom-w.YTop)
+		}
+	}
+	// no words given, use the cells
+	if len(inRow) == 0 {
+		cells := make([]box.Box, 0)
+		for _, cell := range row {
+			if cell.Content != "" {
+				cells = append(cells, cell)
+			}
+		}
+		return cells
+	}
+	sort.Slice(inRow, func(i, j int) bool { return inRow[i].XLeft < inRow[j].XLeft })
+	sort.Float64s(heights)
+	// words further apart than the typical word height are in different phrases
+	maxGap := heights[len(heights)/2]
+
+	result := []box.Box{inRow[0]}
+	for _, w := range inRow[1:] {
+		last := &result[len(result)-1]
+		if w.XLeft-last.XRight < maxGap {
+			last.XRight = max(last.XRight, w.XRight)
+			last.YTop = min(last.YTop, w.YTop)
+			last.YBottom = max(last.YBottom, w.YBottom)
+			last.Content = last.Content + " " + w.Content
+			continue
+		}
+		result = append(result, w)
+	}
+	return result
+}
+
+// parentLabels returns the label of the parent row that spans each column
+func parentLabels(row []box.Box, columns []box.Box, words []box.Box) []string {
+	labels := make([]string, len(columns))
+	overlaps := make([]float64, len(columns))
+	for _, phrase := range phrases(row, words) {
+		spans := false
+		for j, column := range columns {
+			overlap := min(phrase.XRight, column.XRight) - max(phrase.XLeft, column.XLeft)
+			if overlap > 0 && overlap > overlaps[j] {
+				labels[j] = phrase.Content
+				overlaps[j] = overlap
+				spans = true
+			}
+		}
+		if spans {
+			continue
+		}
+		// the phrase is in the gutter between two columns, so it spans both
+		for j := range columns {
+			if columns[j].XRight <= phrase.XLeft && (j == len(columns)-1 || columns[j+1].XLeft >= phrase.XRight) {
+				if labels[j] == "" {
+					labels[j] = phrase.Content
+				}
+				if j+1 < len(columns) && labels[j+1] == "" {
+					labels[j+1] = phrase.Content
+				}
+			}
+		}
+	}
+
+	// a parent spans the columns up until the next parent
+	first := -1
+	last := -1
+	for j, label := range labels {
+		if label == "" {
+			continue
+		}
+		if first == -1 {
+			first = j
+		}
+		last = j
+	}
+	if first == -1 {
+		return labels
+	}
+	for j := first + 1; j < last; j++ {
+		if labels[j] == "" {
+			labels[j] = labels[j-1]
+		}
+	}
+	// the last parent spans as many columns as the one before it
+	lastStart := last
+	for lastStart > first && labels[lastStart-1] == labels[last] {
+		lastStart--
+	}
+	if lastStart > first {
+		previousStart := lastStart - 1
+		for previousStart > first && labels[previousStart-1] == labels[lastStart-1] {
+			previousStart--
+		}
+		width := lastStart - previousStart
+		for j := last + 1; j < len(labels) && j < lastStart+width; j++ {
+			labels[j] = labels[last]
+		}
+	}
+	return labels
+}
+
+func min(f1, f2 float64) float64 {
+	if f1 < f2 {
+		return f1
+	}
+	return f2
+}
+
+func max(f1, f2 float64) float64 {
+	if f1 < f2 {
+		return f2
+	}
+	return f1
+}
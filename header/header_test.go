@@ -0,0 +1,77 @@
+package header
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/vegarsti/extract/box"
+)
+
+// word returns a box on row i
+func word(text string, i int, left, right float64) box.Box {
+	top := 0.1 + float64(i)*0.05
+	return box.Box{Content: text, XLeft: left, XRight: right, YTop: top, YBottom: top + 0.02, Page: 1}
+}
+
+// body returns the words of rows of a name, a revenue and a cost, starting at row i
+func body(i int) []box.Box {
+	words := make([]box.Box, 0)
+	for k := 0; k < 4; k++ {
+		words = append(words,
+			word(fmt.Sprintf("Item%d", k), i+k, 0.1, 0.25),
+			word(fmt.Sprintf("%d00", k+1), i+k, 0.42, 0.5),
+			word(fmt.Sprintf("%d0", k+1), i+k, 0.64, 0.7),
+		)
+	}
+	return words
+}
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		name       string
+		words      []box.Box
+		headerRows int
+		columns    []string
+	}{
+		{
+			name: "single",
+			words: append([]box.Box{
+				word("Name", 0, 0.1, 0.2), word("Revenue", 0, 0.4, 0.5), word("Cost", 0, 0.6, 0.7),
+			}, body(1)...),
+			headerRows: 1,
+			columns:    []string{"Name", "Revenue", "Cost"},
+		},
+		{
+			name: "stacked",
+			words: append([]box.Box{
+				word("2020", 0, 0.47, 0.67),
+				word("Name", 1, 0.1, 0.2), word("Revenue", 1, 0.4, 0.5), word("Cost", 1, 0.6, 0.7),
+			}, body(2)...),
+			headerRows: 2,
+			columns:    []string{"Name", "2020 / Revenue", "2020 / Cost"},
+		},
+		{
+			// the label of the first column is next to the parent header
+			name: "stacked with the stub label above",
+			words: append([]box.Box{
+				word("Name", 0, 0.1, 0.2), word("2020", 0, 0.47, 0.67),
+				word("Revenue", 1, 0.4, 0.5), word("Cost", 1, 0.6, 0.7),
+			}, body(2)...),
+			headerRows: 2,
+			columns:    []string{"Name", "2020 / Revenue", "2020 / Cost"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, _ := box.ToTable(tt.words)
+			columns, headerRows := Flatten(rows, tt.words)
+			if headerRows != tt.headerRows {
+				t.Errorf("got %d header rows, want %d", headerRows, tt.headerRows)
+			}
+			if !reflect.DeepEqual(columns, tt.columns) {
+				t.Errorf("got columns %q, want %q", columns, tt.columns)
+			}
+		})
+	}
+}
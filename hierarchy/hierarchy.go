@@ -0,0 +1,114 @@
+package hierarchy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/vegarsti/extract/box"
+)
+
+// Separator is put between the levels of a path, e.g. "Operating costs > Salaries"
+const Separator = " > "
+
+// Levels returns the indentation level of the first column of each row,
+// e.g. in a chart of accounts, where sub-accounts are indented below their parent.
+// Rows where the text starts furthest to the left have level 0, the next indentation
+// has level 1, and so on. Rows with an empty first column have level -1.
+// If the first column is mostly in a right-to-left script, the whole column is measured
+// in a mirrored frame, so the text is indented from the right.
+// words are the word boxes the rows were made from.
+func Levels(rows [][]box.Box, words []box.Box) []int {
+	first := make([][]box.Box, 0, len(rows))
+	for _, row := range rows {
+		if len(row) > 0 {
+			first = append(first, row[:1])
+		}
+	}
+	rtl := box.IsRTL(first)
+	lefts := make([]float64, len(rows))
+	heights := make([]float64, 0)
+	for i, row := range rows {
+		lefts[i] = -1
+		if len(row) == 0 || row[0].Content == "" {
+			continue
+		}
+		cell := row[0]
+		for _, w := range words {
+			if w.Page != cell.Page {
+				continue
+			}
+			if !cell.Rect().Contains(w.Rect().Center(), 0) {
+				continue
+			}
+			// the distance from the edge the text starts at
+			left := w.XLeft
+			if rtl {
+				left = 1 - w.XRight
+			}
+			if lefts[i] == -1 || left < lefts[i] {
+				lefts[i] = left
+			}
+			heights = append(heights, w.YBottom-w.YTop)
+		}
+	}
+
+	levels := make([]int, len(rows))
+	for i := range levels {
+		levels[i] = -1
+	}
+	if len(heights) == 0 {
+		return levels
+	}
+	// indentations closer than half the height of a word are the same
+	sort.Float64s(heights)
+	tolerance := heights[len(heights)/2] / 2
+
+	sorted := make([]float64, 0)
+	for _, left := range lefts {
+		if left != -1 {
+			sorted = append(sorted, left)
+		}
+	}
+	sort.Float64s(sorted)
+	clusterStarts := []float64{sorted[0]}
+	previous := sorted[0]
+	for _, left := range sorted[1:] {
+		if left-previous > tolerance {
+			clusterStarts = append(clusterStarts, left)
+		}
+		previous = left
+	}
+
+	for i, left := range lefts {
+		if left == -1 {
+			continue
+		}
+		level := 0
+		for k, start := range clusterStarts {
+			if left >= start {
+				level = k
+			}
+		}
+		levels[i] = level
+	}
+	return levels
+}
+
+// Paths returns the path of the first column of each row through the levels above it,
+// e.g. "Operating costs > Salaries". Rows with level -1 have an empty path.
+func Paths(table [][]string, levels []int) []string {
+	paths := make([]string, len(table))
+	stack := make([]string, 0)
+	for i, row := range table {
+		level := levels[i]
+		if level < 0 || len(row) == 0 {
+			continue
+		}
+		if level > len(stack) {
+			level = len(stack)
+		}
+		stack = append(stack[:level], row[0])
+		paths[i] = strings.Join(stack, Separator)
+	}
+	return paths
+}
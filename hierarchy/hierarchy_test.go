@@ -0,0 +1,70 @@
+package hierarchy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vegarsti/extract/box"
+)
+
+// cell returns a box on row i, which is also the word it was made from
+func cell(text string, i int, left, right float64) box.Box {
+	top := 0.1 + float64(i)*0.05
+	return box.Box{Content: text, XLeft: left, XRight: right, YTop: top, YBottom: top + 0.02, Page: 1}
+}
+
+func TestLevels(t *testing.T) {
+	tests := []struct {
+		name  string
+		cells []box.Box
+		want  []int
+	}{
+		{
+			name: "ltr",
+			cells: []box.Box{
+				cell("Operating costs", 0, 0.1, 0.3),
+				cell("Salaries", 1, 0.15, 0.3),
+				cell("Rent", 2, 0.15, 0.3),
+				cell("Other", 3, 0.1, 0.3),
+			},
+			want: []int{0, 1, 1, 0},
+		},
+		{
+			// indented from the right: the first column ends at 0.9 for level 0
+			name: "rtl",
+			cells: []box.Box{
+				cell("הוצאות תפעול", 0, 0.7, 0.9),
+				cell("שכר", 1, 0.75, 0.85),
+				cell("שכירות", 2, 0.7, 0.85),
+				cell("אחר", 3, 0.8, 0.9),
+			},
+			want: []int{0, 1, 1, 0},
+		},
+		{
+			// a left-to-right word in a right-to-left column is measured from the right too
+			name: "rtl with ltr row",
+			cells: []box.Box{
+				cell("הוצאות תפעול", 0, 0.7, 0.9),
+				cell("IT", 1, 0.8, 0.85),
+				cell("שכירות", 2, 0.7, 0.85),
+			},
+			want: []int{0, 1, 1},
+		},
+		{
+			name:  "empty first column",
+			cells: []box.Box{cell("", 0, 0.1, 0.3), cell("Rent", 1, 0.1, 0.3)},
+			want:  []int{-1, 0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows := make([][]box.Box, len(test.cells))
+			for i, c := range test.cells {
+				rows[i] = []box.Box{c}
+			}
+			if got := Levels(rows, test.cells); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Levels() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -2,51 +2,492 @@ package html
 
 import (
 	"bytes"
-	"text/template"
+	"html/template"
+	"strings"
 
 	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/geometry"
 )
 
+// Checked and Unchecked are shown for selection elements such as checkboxes
+const Checked = "☑"
+const Unchecked = "☐"
+
 type Cell struct {
 	Text string
+	// Located is true if the cell can be highlighted in the image, i.e. it has a region on the first page
+	Located    bool
+	Rect       geometry.Rect
+	RowSpan    int
+	ColumnSpan int
 }
 
 type Row struct {
+	// Cells are the cells that start in the row, i.e. not those covered by a cell spanning several rows or columns
 	Cells []Cell
+	// Header is true for the header rows
+	Header bool
+}
+
+// URLs of the files the page links to
+type URLs struct {
+	Image string
+	PDF   string
+	CSV   string
+	JSON  string
+	// Words and Cells are the image with the boxes of the words and the cells drawn on it,
+	// which are only made for PNG images
+	Words string
+	Cells string
+	// Corrections is where the table is sent when it has been edited, and the page can't be edited if it's empty
+	Corrections string
 }
 
 type Table struct {
-	Rows     []Row
-	ImageURL string
-	CSVURL   string
-	PDFURL   string
+	Rows []Row
+	URLs URLs
+	// Extracted is the table as it is edited on the page
+	Extracted *extract.Table
 }
 
-var imageHTMLTemplateString = `
-<!DOCTYPE html>
-<html>
-	<head>
-		<meta charset="UTF-8">
+var style = `
 		<style>
 			table, th, td {
 				border: 1px solid black;
 				border-collapse: collapse;
 				padding: 5px;
 			}
-		</style>
+			td.located:hover, th.located:hover {
+				background-color: #ffeb99;
+			}
+			.document {
+				position: relative;
+				display: inline-block;
+			}
+			.document img {
+				display: block;
+				max-width: 100%;
+			}
+			.highlight {
+				position: absolute;
+				display: none;
+				border: 2px solid #e6a700;
+				background-color: rgba(255, 235, 153, 0.4);
+				pointer-events: none;
+			}
+			table.editing td, table.editing th {
+				min-width: 2em;
+				cursor: text;
+			}
+			table.editing .selected {
+				outline: 2px solid #1a73e8;
+			}
+		</style>`
+
+var downloads = `
+		Download <a href="{{.URLs.CSV}}">CSV</a>, <a href="{{.URLs.JSON}}">JSON</a>{{if .URLs.Words}}, <a href="{{.URLs.Words}}">words</a> or <a href="{{.URLs.Cells}}">cells</a>{{end}}.`
+
+var toolbar = `{{if .URLs.Corrections}}
+		<br /><br />
+		<button id="edit">Edit table</button>
+		<span id="toolbar" hidden>
+			<button data-action="insertRowAbove">Insert row above</button>
+			<button data-action="insertRowBelow">Insert row below</button>
+			<button data-action="deleteRow">Delete row</button>
+			<button data-action="insertColumnLeft">Insert column left</button>
+			<button data-action="insertColumnRight">Insert column right</button>
+			<button data-action="deleteColumn">Delete column</button>
+			<button data-action="mergeRight">Merge right</button>
+			<button data-action="mergeDown">Merge down</button>
+			<button data-action="split">Split</button>
+			<label>Header rows <input id="header-rows" type="number" min="0" style="width: 3em"></label>
+			<label>API key <input id="api-key" type="password" autocomplete="off"></label>
+			<button id="save">Save</button>
+			<button id="cancel">Cancel</button>
+		</span>
+		<span id="status"></span>{{end}}`
+
+var table = `
+		<table id="table">{{range .Rows}}
+			<tr>{{$header := .Header}}{{range .Cells}}
+				{{if $header}}<th{{else}}<td{{end}}{{if gt .RowSpan 1}} rowspan="{{.RowSpan}}"{{end}}{{if gt .ColumnSpan 1}} colspan="{{.ColumnSpan}}"{{end}}{{if .Located}} class="located" data-left="{{.Rect.Left}}" data-top="{{.Rect.Top}}" data-width="{{.Rect.Width}}" data-height="{{.Rect.Height}}"{{end}}>{{.Text}}{{if $header}}</th>{{else}}</td>{{end}}{{end}}
+			</tr>{{end}}
+		</table>`
+
+// script highlights where a cell was found in the image when hovering over it, if the page has an image,
+// and lets the table be edited and sent to be stored, if it can be corrected
+var script = `
+		<script>
+			var element = document.getElementById("table");
+			var highlight = document.getElementById("highlight");
+			if (highlight) {
+				element.addEventListener("mouseover", function (event) {
+					var cell = event.target.closest(".located");
+					if (!cell) {
+						highlight.style.display = "none";
+						return;
+					}
+					highlight.style.left = cell.dataset.left * 100 + "%";
+					highlight.style.top = cell.dataset.top * 100 + "%";
+					highlight.style.width = cell.dataset.width * 100 + "%";
+					highlight.style.height = cell.dataset.height * 100 + "%";
+					highlight.style.display = "block";
+				});
+				element.addEventListener("mouseleave", function () {
+					highlight.style.display = "none";
+				});
+			}
+			// switch between the image and the image with the words or cells drawn on it
+			document.querySelectorAll("input[name=overlay]").forEach(function (input) {
+				input.addEventListener("change", function () {
+					document.getElementById("document").src = input.value;
+				});
+			});
+		</script>{{if .URLs.Corrections}}
+		<script>
+			var table = {{.Extracted}};
+			var corrections = {{.URLs.Corrections}};
+			// edited is a copy of the table while it's being edited, and selected is the row and column of the selected cell
+			var edited = null;
+			var selected = null;
+
+			function status(message) {
+				document.getElementById("status").textContent = message;
+			}
+
+			function blank(page) {
+				return {text: "", rect: {left: 0, right: 0, top: 0, bottom: 0}, page: page, confidence: 0, row_span: 1, column_span: 1};
+			}
+
+			function empty(rect) {
+				return rect.right <= rect.left || rect.bottom <= rect.top;
+			}
+
+			function width(t) {
+				return t.rows.reduce(function (w, row) { return Math.max(w, row.length); }, 0);
+			}
+
+			// covered is true for the cells covered by a cell above or to the left of them
+			function covered(t) {
+				var c = t.rows.map(function (row) { return row.map(function () { return false; }); });
+				t.rows.forEach(function (row, i) {
+					row.forEach(function (cell, j) {
+						if (c[i][j]) {
+							return;
+						}
+						for (var k = i; k < i + cell.row_span && k < t.rows.length; k++) {
+							for (var l = j; l < j + cell.column_span && l < t.rows[k].length; l++) {
+								if (k !== i || l !== j) {
+									c[k][l] = true;
+								}
+							}
+						}
+					});
+				});
+				return c;
+			}
+
+			function text(cell) {
+				if (cell.selected === true) {
+					return "☑";
+				}
+				if (cell.selected === false) {
+					return "☐";
+				}
+				return cell.text;
+			}
+
+			function render(t, editing) {
+				var c = covered(t);
+				element.innerHTML = "";
+				element.classList.toggle("editing", editing);
+				t.rows.forEach(function (row, i) {
+					var tr = element.insertRow();
+					row.forEach(function (cell, j) {
+						if (c[i][j]) {
+							return;
+						}
+						var td = document.createElement(i < t.header_rows ? "th" : "td");
+						td.textContent = text(cell);
+						if (cell.row_span > 1) {
+							td.rowSpan = cell.row_span;
+						}
+						if (cell.column_span > 1) {
+							td.colSpan = cell.column_span;
+						}
+						if (highlight && !empty(cell.rect)) {
+							td.className = "located";
+							td.dataset.left = cell.rect.left;
+							td.dataset.top = cell.rect.top;
+							td.dataset.width = cell.rect.right - cell.rect.left;
+							td.dataset.height = cell.rect.bottom - cell.rect.top;
+						}
+						if (editing) {
+							if (cell.selected === undefined || cell.selected === null) {
+								td.contentEditable = "true";
+								td.addEventListener("input", function () { cell.text = td.textContent; });
+							} else {
+								// selection elements are ticked and unticked by clicking them
+								td.addEventListener("click", function () {
+									cell.selected = !cell.selected;
+									td.textContent = text(cell);
+								});
+							}
+							td.addEventListener("focus", function () { select(i, j, td); });
+							td.addEventListener("click", function () { select(i, j, td); });
+							if (selected && selected[0] === i && selected[1] === j) {
+								td.classList.add("selected");
+							}
+						}
+						tr.appendChild(td);
+					});
+				});
+			}
+
+			function select(i, j, td) {
+				selected = [i, j];
+				element.querySelectorAll(".selected").forEach(function (e) { e.classList.remove("selected"); });
+				td.classList.add("selected");
+			}
+
+			// a new row at index k, which extends the cells spanning across it
+			function insertRow(k) {
+				var c = covered(edited);
+				edited.rows.forEach(function (row, i) {
+					row.forEach(function (cell, j) {
+						if (!c[i][j] && i < k && k < i + cell.row_span) {
+							cell.row_span++;
+						}
+					});
+				});
+				var neighbour = edited.rows[Math.min(k, edited.rows.length - 1)];
+				var page = neighbour.length > 0 ? neighbour[0].page : 0;
+				var row = [];
+				for (var j = 0; j < width(edited); j++) {
+					row.push(blank(page));
+				}
+				edited.rows.splice(k, 0, row);
+				if (k < edited.header_rows) {
+					edited.header_rows++;
+				}
+			}
+
+			function deleteRow(k) {
+				if (edited.rows.length === 1) {
+					return;
+				}
+				var c = covered(edited);
+				edited.rows.forEach(function (row, i) {
+					row.forEach(function (cell, j) {
+						if (c[i][j] || i + cell.row_span <= k || i > k) {
+							return;
+						}
+						cell.row_span--;
+						// a cell starting in the row moves down to the row below it
+						if (i === k && cell.row_span > 0) {
+							edited.rows[k + 1][j] = cell;
+						}
+					});
+				});
+				edited.rows.splice(k, 1);
+				if (k < edited.header_rows) {
+					edited.header_rows--;
+				}
+			}
+
+			// a new column at index k, which extends the cells spanning across it
+			function insertColumn(k) {
+				var c = covered(edited);
+				edited.rows.forEach(function (row, i) {
+					row.forEach(function (cell, j) {
+						if (!c[i][j] && j < k && k < j + cell.column_span) {
+							cell.column_span++;
+						}
+					});
+				});
+				edited.rows.forEach(function (row) {
+					row.splice(k, 0, blank(row.length > 0 ? row[0].page : 0));
+				});
+			}
+
+			function deleteColumn(k) {
+				if (width(edited) === 1) {
+					return;
+				}
+				var c = covered(edited);
+				edited.rows.forEach(function (row, i) {
+					row.forEach(function (cell, j) {
+						if (c[i][j] || j + cell.column_span <= k || j > k) {
+							return;
+						}
+						cell.column_span--;
+						// a cell starting in the column moves right to the column next to it
+						if (j === k && cell.column_span > 0) {
+							row[k + 1] = cell;
+						}
+					});
+				});
+				edited.rows.forEach(function (row) {
+					row.splice(k, 1);
+				});
+			}
+
+			function union(r, o) {
+				if (empty(r)) {
+					return o;
+				}
+				if (empty(o)) {
+					return r;
+				}
+				return {left: Math.min(r.left, o.left), right: Math.max(r.right, o.right), top: Math.min(r.top, o.top), bottom: Math.max(r.bottom, o.bottom)};
+			}
+
+			// merge the cell at row i and column j with the cell at row k and column l,
+			// which must start at the same row or column and have the same height or width
+			function merge(i, j, k, l) {
+				var c = covered(edited);
+				if (k >= edited.rows.length || l >= edited.rows[k].length || c[k][l]) {
+					status("There is no cell to merge with.");
+					return;
+				}
+				var cell = edited.rows[i][j];
+				var other = edited.rows[k][l];
+				if (i === k && cell.row_span !== other.row_span) {
+					status("Only cells of the same height can be merged.");
+					return;
+				}
+				if (j === l && cell.column_span !== other.column_span) {
+					status("Only cells of the same width can be merged.");
+					return;
+				}
+				cell.text = [cell.text, other.text].filter(function (s) { return s.trim() !== ""; }).join(" ");
+				cell.rect = union(cell.rect, other.rect);
+				if (i === k) {
+					cell.column_span += other.column_span;
+				} else {
+					cell.row_span += other.row_span;
+				}
+				edited.rows[k][l] = blank(other.page);
+			}
+
+			var actions = {
+				insertRowAbove: function (i, j) { insertRow(i); },
+				insertRowBelow: function (i, j) { insertRow(i + edited.rows[i][j].row_span); },
+				deleteRow: function (i, j) { deleteRow(i); },
+				insertColumnLeft: function (i, j) { insertColumn(j); },
+				insertColumnRight: function (i, j) { insertColumn(j + edited.rows[i][j].column_span); },
+				deleteColumn: function (i, j) { deleteColumn(j); },
+				mergeRight: function (i, j) { merge(i, j, i, j + edited.rows[i][j].column_span); },
+				mergeDown: function (i, j) { merge(i, j, i + edited.rows[i][j].row_span, j); },
+				split: function (i, j) {
+					edited.rows[i][j].row_span = 1;
+					edited.rows[i][j].column_span = 1;
+				}
+			};
+
+			document.querySelectorAll("[data-action]").forEach(function (button) {
+				button.addEventListener("click", function () {
+					if (!selected) {
+						status("Select a cell first.");
+						return;
+					}
+					status("");
+					var rows = edited.rows.length;
+					var columns = width(edited);
+					actions[button.dataset.action](selected[0], selected[1]);
+					// the selected cell is gone if its row or column was deleted
+					if (edited.rows.length < rows || width(edited) < columns) {
+						selected = null;
+					}
+					document.getElementById("header-rows").value = edited.header_rows;
+					render(edited, true);
+				});
+			});
+
+			document.getElementById("header-rows").addEventListener("change", function (event) {
+				edited.header_rows = Math.max(0, Math.min(parseInt(event.target.value, 10) || 0, edited.rows.length));
+				event.target.value = edited.header_rows;
+				render(edited, true);
+			});
+
+			function stop() {
+				edited = null;
+				selected = null;
+				document.getElementById("toolbar").hidden = true;
+				document.getElementById("edit").hidden = false;
+				render(table, false);
+			}
+
+			document.getElementById("edit").addEventListener("click", function () {
+				edited = JSON.parse(JSON.stringify(table));
+				// pad short rows, so every row has a cell in every column
+				var w = width(edited);
+				edited.rows.forEach(function (row) {
+					while (row.length < w) {
+						row.push(blank(row.length > 0 ? row[0].page : 0));
+					}
+				});
+				document.getElementById("header-rows").value = edited.header_rows;
+				document.getElementById("toolbar").hidden = false;
+				document.getElementById("edit").hidden = true;
+				status("");
+				render(edited, true);
+			});
+
+			document.getElementById("cancel").addEventListener("click", function () {
+				status("");
+				stop();
+			});
+
+			document.getElementById("save").addEventListener("click", function () {
+				// anyone can see the page, so only those with an API key can change the table
+				var apiKey = document.getElementById("api-key").value.trim();
+				if (apiKey === "") {
+					status("Enter your API key to save.");
+					return;
+				}
+				status("Saving...");
+				fetch(corrections, {
+					method: "PUT",
+					headers: {"Content-Type": "application/json", "api-key": apiKey},
+					body: JSON.stringify(edited)
+				}).then(function (response) {
+					return response.json().then(function (body) {
+						if (!response.ok) {
+							throw new Error(body.error);
+						}
+						return body;
+					});
+				}).then(function (corrected) {
+					table = corrected;
+					stop();
+					status("Saved. The downloads have the corrected table.");
+				}).catch(function (err) {
+					status("Saving failed: " + err.message);
+				});
+			});
+		</script>{{end}}`
+
+var imageHTMLTemplateString = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">` + style + `
 	</head>
 	<body>
 		Extract Table by Vegard Stikbakke. Go back <a href="https://extract-table.com">home</a>.
-		<br /><br />
-		<a href="{{.CSVURL}}">Download CSV.</a>
-		<br /><br />
-		<table>{{range .Rows}}
-			<tr>{{range .Cells}}
-				<td>{{.Text}}</td>{{end}}
-			</tr>{{end}}
-		</table>
-		<br />
-		<img src="{{.ImageURL}}">
+		<br /><br />` + downloads + toolbar + `
+		<br /><br />` + table + `
+		<br />{{if .URLs.Words}}
+		Show
+		<label><input type="radio" name="overlay" value="{{.URLs.Image}}" checked> image</label>
+		<label><input type="radio" name="overlay" value="{{.URLs.Words}}"> words</label>
+		<label><input type="radio" name="overlay" value="{{.URLs.Cells}}"> cells</label>
+		<br /><br />{{end}}
+		<div class="document">
+			<img id="document" src="{{.URLs.Image}}">
+			<div id="highlight" class="highlight"></div>
+		</div>` + script + `
 	</body>
 </html>
 `
@@ -55,27 +496,14 @@ var pdfHTMLTemplateString = `
 <!DOCTYPE html>
 <html>
 	<head>
-		<meta charset="UTF-8">
-		<style>
-			table, th, td {
-				border: 1px solid black;
-				border-collapse: collapse;
-				padding: 5px;
-			}
-		</style>
+		<meta charset="UTF-8">` + style + `
 	</head>
 	<body>
 		Extract Table by Vegard Stikbakke. Go back <a href="https://extract-table.com">home</a>.
-		<br /><br />
-		<a href="{{.CSVURL}}">Download CSV.</a>
-		<br /><br />
-		<table>{{range .Rows}}
-			<tr>{{range .Cells}}
-				<td>{{.Text}}</td>{{end}}
-			</tr>{{end}}
-		</table>
+		<br /><br />` + downloads + toolbar + `
+		<br /><br />` + table + `
 		<br />
-		<a href="{{.PDFURL}}">Original PDF.</a>
+		<a href="{{.URLs.PDF}}">Original PDF.</a>` + script + `
 	</body>
 </html>
 `
@@ -83,22 +511,82 @@ var pdfHTMLTemplateString = `
 var imageHTMLTemplate = template.Must(template.New("imageTable").Parse(imageHTMLTemplateString))
 var pdfHTMLTemplate = template.Must(template.New("pdfTable").Parse(pdfHTMLTemplateString))
 
-func FromTable(stringTable [][]string, mediaType extract.FileType, imageURL string, csvURL string, pdfURL string) []byte {
-	var table Table
-	table.CSVURL = csvURL
+// text of the cell, with selection elements shown as ☑ or ☐
+func text(cell extract.Cell) string {
+	if cell.Selected != nil && *cell.Selected {
+		return Checked
+	}
+	if cell.Selected != nil {
+		return Unchecked
+	}
+	return cell.Text
+}
+
+// Fragment returns the table as a bare <table> element, to be embedded in other pages.
+// The header is a single row of the column names in <thead>, and the rest of the rows are in <tbody>.
+func Fragment(table *extract.Table) string {
+	width := table.Width()
+	if width == 0 {
+		return ""
+	}
+	s := &strings.Builder{}
+	s.WriteString("<table>\n")
+	body := table.Rows
+	if table.HeaderRows > 0 && table.HeaderRows <= len(table.Rows) {
+		s.WriteString("<thead>\n<tr>")
+		for j := 0; j < width; j++ {
+			name := ""
+			if j < len(table.Columns) {
+				name = table.Columns[j]
+			}
+			s.WriteString(`<th scope="col">` + template.HTMLEscapeString(name) + "</th>")
+		}
+		s.WriteString("</tr>\n</thead>\n")
+		body = table.Rows[table.HeaderRows:]
+	}
+	s.WriteString("<tbody>\n")
+	for _, row := range body {
+		s.WriteString("<tr>")
+		for j := 0; j < width; j++ {
+			cell := extract.Cell{}
+			if j < len(row) {
+				cell = row[j]
+			}
+			s.WriteString("<td>" + template.HTMLEscapeString(text(cell)) + "</td>")
+		}
+		s.WriteString("</tr>\n")
+	}
+	s.WriteString("</tbody>\n</table>\n")
+	return s.String()
+}
+
+// FromTable renders the table as a page, with the image or a link to the PDF it was extracted from.
+// Hovering over a cell highlights where it was found in the image.
+// Selection elements such as checkboxes are shown as ☑ or ☐.
+// If urls has a URL for corrections, the table can be edited on the page and sent there.
+func FromTable(extracted *extract.Table, mediaType extract.FileType, urls URLs) []byte {
+	table := Table{URLs: urls, Extracted: extracted}
 	buf := bytes.NewBufferString("")
-	for _, row := range stringTable {
-		var r Row
-		for _, cell := range row {
-			r.Cells = append(r.Cells, Cell{cell})
+	covered := extracted.Covered()
+	for i, row := range extracted.Rows {
+		r := Row{Header: i < extracted.HeaderRows}
+		for j, cell := range row {
+			if covered[i][j] {
+				continue
+			}
+			r.Cells = append(r.Cells, Cell{
+				Text:       text(cell),
+				Located:    mediaType != extract.PDF && !cell.Rect.Empty(),
+				Rect:       cell.Rect,
+				RowSpan:    cell.RowSpan,
+				ColumnSpan: cell.ColumnSpan,
+			})
 		}
 		table.Rows = append(table.Rows, r)
 	}
-	if mediaType == "pdf" {
-		table.PDFURL = pdfURL
+	if mediaType == extract.PDF {
 		pdfHTMLTemplate.Execute(buf, table)
 	} else {
-		table.ImageURL = imageURL
 		imageHTMLTemplate.Execute(buf, table)
 	}
 	return buf.Bytes()
@@ -9,6 +9,7 @@ import (
 	"image/png"
 
 	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/geometry"
 )
 
 // AddBoxes adds bounding boxes to the base64 encoded image and returns a new base64 encoded image
@@ -26,7 +27,7 @@ func AddBoxes(imageBytes []byte, boxes []box.Box) ([]byte, error) {
 
 	// Draw the boxes
 	for _, box := range boxes {
-		drawBox(outputImg, box, bounds)
+		drawBox(outputImg, box.Rect(), bounds)
 	}
 
 	// Encode the modified image back to base64
@@ -38,17 +39,16 @@ func AddBoxes(imageBytes []byte, boxes []box.Box) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// drawBox draws a single Box on the image
-func drawBox(img *image.RGBA, box box.Box, bounds image.Rectangle) {
+// drawBox draws the outline of a rectangle on the image
+func drawBox(img *image.RGBA, r geometry.Rect, bounds image.Rectangle) {
 	col := color.RGBA{255, 0, 0, 255} // Red color for the box outline
-	imgWidth := bounds.Dx()
-	imgHeight := bounds.Dy()
 
 	// Convert normalized coordinates to pixel coordinates
-	x1 := int(box.XLeft * float64(imgWidth))
-	x2 := int(box.XRight * float64(imgWidth))
-	y1 := int(box.YTop * float64(imgHeight))
-	y2 := int(box.YBottom * float64(imgHeight))
+	pixels := r.Scale(float64(bounds.Dx()), float64(bounds.Dy()))
+	x1 := int(pixels.Left)
+	x2 := int(pixels.Right)
+	y1 := int(pixels.Top)
+	y2 := int(pixels.Bottom)
 
 	// Draw the rectangle outline
 	for x := x1; x <= x2; x++ {
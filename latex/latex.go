@@ -0,0 +1,98 @@
+package latex
+
+import (
+	"strings"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/schema"
+)
+
+// MediaType of LaTeX
+const MediaType = "application/x-latex"
+
+// Checked and Unchecked are written for selection elements such as checkboxes,
+// and need the amssymb package
+const Checked = `$\boxtimes$`
+const Unchecked = `$\square$`
+
+// FromTable writes the table as a LaTeX tabular with the rules of the booktabs package
+func FromTable(table *extract.Table, l normalize.Locale) string {
+	width := table.Width()
+	if width == 0 {
+		return ""
+	}
+	columns := schema.Infer(table.Columns, normalize.Body(table, l))
+	alignments := make([]string, width)
+	for j := range alignments {
+		alignments[j] = "l"
+		if j < len(columns) && columns[j].Type.Numeric() {
+			alignments[j] = "r"
+		}
+		if j < len(columns) && columns[j].Type == schema.Boolean {
+			alignments[j] = "c"
+		}
+	}
+
+	s := &strings.Builder{}
+	s.WriteString(`\begin{tabular}{` + strings.Join(alignments, "") + "}\n")
+	s.WriteString("\\toprule\n")
+	body := table.Rows
+	if table.HeaderRows > 0 && table.HeaderRows <= len(table.Rows) {
+		header := make([]string, width)
+		for j := range header {
+			if j < len(table.Columns) {
+				header[j] = Escape(table.Columns[j])
+			}
+		}
+		writeRow(s, header)
+		s.WriteString("\\midrule\n")
+		body = table.Rows[table.HeaderRows:]
+	}
+	for _, row := range body {
+		line := make([]string, width)
+		for j, cell := range row {
+			line[j] = text(cell)
+		}
+		writeRow(s, line)
+	}
+	s.WriteString("\\bottomrule\n")
+	s.WriteString("\\end{tabular}\n")
+	return s.String()
+}
+
+// text of the cell, with selection elements shown as boxes
+func text(cell extract.Cell) string {
+	if cell.Selected != nil && *cell.Selected {
+		return Checked
+	}
+	if cell.Selected != nil {
+		return Unchecked
+	}
+	return Escape(cell.Text)
+}
+
+func writeRow(s *strings.Builder, row []string) {
+	s.WriteString(strings.Join(row, " & ") + " \\\\\n")
+}
+
+var replacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`&`, `\&`,
+	`%`, `\%`,
+	`$`, `\$`,
+	`#`, `\#`,
+	`_`, `\_`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`~`, `\textasciitilde{}`,
+	`^`, `\textasciicircum{}`,
+	"\r\n", " ",
+	"\n", " ",
+)
+
+// Escape the characters that are special in LaTeX, so the text is typeset as it is.
+// Newlines would end the row, so they are spaces.
+func Escape(s string) string {
+	return replacer.Replace(strings.TrimSpace(s))
+}
@@ -0,0 +1,63 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/schema"
+)
+
+// MediaType of Markdown
+const MediaType = "text/markdown"
+
+// FromTable writes the table as a GitHub Flavored Markdown table, with numeric columns aligned to the right
+func FromTable(table *extract.Table, l normalize.Locale) string {
+	width := table.Width()
+	if width == 0 {
+		return ""
+	}
+	lines := table.Flattened()
+	header := make([]string, width)
+	if table.HeaderRows > 0 && table.HeaderRows <= len(table.Rows) {
+		copy(header, lines[0])
+		lines = lines[1:]
+	}
+	columns := schema.Infer(table.Columns, normalize.Body(table, l))
+
+	s := &strings.Builder{}
+	writeRow(s, header)
+	alignments := make([]string, width)
+	for j := range alignments {
+		alignments[j] = ":---"
+		if j < len(columns) && columns[j].Type.Numeric() {
+			alignments[j] = "---:"
+		}
+		if j < len(columns) && columns[j].Type == schema.Boolean {
+			alignments[j] = ":---:"
+		}
+	}
+	s.WriteString("|" + strings.Join(alignments, "|") + "|\n")
+	for _, line := range lines {
+		row := make([]string, width)
+		copy(row, line)
+		writeRow(s, row)
+	}
+	return s.String()
+}
+
+func writeRow(s *strings.Builder, row []string) {
+	s.WriteString("|")
+	for _, cell := range row {
+		s.WriteString(" " + escape(cell) + " |")
+	}
+	s.WriteString("\n")
+}
+
+// escape the text so it stays in its cell: pipes would end the cell, and newlines the row
+func escape(s string) string {
+	s = strings.TrimSpace(s)
+	s = strings.ReplaceAll(s, "|", `\|`)
+	s = strings.ReplaceAll(s, "\r\n", "<br>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,264 @@
+package normalize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vegarsti/extract"
+)
+
+// Locale is how numbers and dates are written
+type Locale struct {
+	Name string
+	// Decimal is the decimal separator
+	Decimal string
+	// Thousands are the separators between groups of digits
+	Thousands []string
+	// DayFirst is true if dates are written with the day before the month, e.g. 31.12.2021
+	DayFirst bool
+}
+
+var US = Locale{Name: "en-US", Decimal: ".", Thousands: []string{","}}
+var UK = Locale{Name: "en-GB", Decimal: ".", Thousands: []string{","}, DayFirst: true}
+var German = Locale{Name: "de-DE", Decimal: ",", Thousands: []string{".", " ", " ", " "}, DayFirst: true}
+var French = Locale{Name: "fr-FR", Decimal: ",", Thousands: []string{" ", " ", " "}, DayFirst: true}
+var Norwegian = Locale{Name: "nb-NO", Decimal: ",", Thousands: []string{" ", ".", " ", " "}, DayFirst: true}
+var Swiss = Locale{Name: "de-CH", Decimal: ".", Thousands: []string{"'", "’"}, DayFirst: true}
+
+var locales = []Locale{US, UK, German, French, Norwegian, Swiss}
+
+// languages are the locales used when only the language of a tag is known
+var languages = map[string]Locale{
+	"en": US,
+	"de": German,
+	"fr": French,
+	"nb": Norwegian,
+	"no": Norwegian,
+	"nn": Norwegian,
+	"es": German,
+	"it": German,
+	"nl": German,
+	"da": German,
+	"sv": French,
+	"fi": French,
+	"pl": French,
+}
+
+// ParseLocale returns the locale for a language tag such as "de-DE" or "fr"
+func ParseLocale(tag string) (Locale, error) {
+	tag = strings.ReplaceAll(strings.TrimSpace(tag), "_", "-")
+	for _, l := range locales {
+		if strings.EqualFold(l.Name, tag) {
+			return l, nil
+		}
+	}
+	language := strings.ToLower(strings.Split(tag, "-")[0])
+	if l, ok := languages[language]; ok {
+		return l, nil
+	}
+	return Locale{}, fmt.Errorf("unknown locale '%s'", tag)
+}
+
+var usNumberRegexp = regexp.MustCompile(`^(\d{1,3}(,\d{3})+(\.\d+)?|\d+\.\d{1,2})$`)
+var europeanNumberRegexp = regexp.MustCompile(`^(\d{1,3}(\.\d{3})+(,\d+)?|\d+,\d{1,2})$`)
+
+const numberAffixes = "$€£¥%()+-− "
+
+var dottedDateRegexp = regexp.MustCompile(`^\d{1,2}\.\d{1,2}\.\d{2,4}$`)
+var slashDateRegexp = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/\d{2,4}$`)
+
+// Detect the locale from the way numbers and dates are written in the table.
+// US is used if there is no evidence either way.
+func Detect(rows [][]string) Locale {
+	us := 0
+	european := 0
+	dayFirst := 0
+	monthFirst := 0
+	for _, row := range rows {
+		for _, cell := range row {
+			cell = strings.TrimSpace(cell)
+			switch {
+			case dottedDateRegexp.MatchString(cell):
+				dayFirst++
+			case slashDateRegexp.MatchString(cell):
+				// only one of the first two numbers can be larger than 12
+				m := slashDateRegexp.FindStringSubmatch(cell)
+				first, _ := strconv.Atoi(m[1])
+				second, _ := strconv.Atoi(m[2])
+				if first > 12 {
+					dayFirst++
+				}
+				if second > 12 {
+					monthFirst++
+				}
+			// signs and currency symbols around a number don't say anything about the locale
+			case europeanNumberRegexp.MatchString(strings.Trim(cell, numberAffixes)):
+				european++
+			case usNumberRegexp.MatchString(strings.Trim(cell, numberAffixes)):
+				us++
+			}
+		}
+	}
+	if european > us {
+		return German
+	}
+	if dayFirst > monthFirst {
+		return UK
+	}
+	return US
+}
+
+// Cell is the text in a cell, before and after normalization
+type Cell struct {
+	Raw        string `json:"raw"`
+	Normalized string `json:"normalized"`
+}
+
+// Table normalizes the text in every cell
+func Table(rows [][]string, l Locale) [][]Cell {
+	cells := make([][]Cell, len(rows))
+	for i, row := range rows {
+		cells[i] = make([]Cell, len(row))
+		for j, raw := range row {
+			cells[i][j] = Cell{Raw: raw, Normalized: Value(raw, l)}
+		}
+	}
+	return cells
+}
+
+// Normalized returns the normalized text of the cells
+func Normalized(cells [][]Cell) [][]string {
+	rows := make([][]string, len(cells))
+	for i := range cells {
+		rows[i] = make([]string, len(cells[i]))
+		for j := range cells[i] {
+			rows[i][j] = cells[i][j].Normalized
+		}
+	}
+	return rows
+}
+
+// Body returns the normalized text in the body of the table,
+// which is normalized from the locale l unless it already has been.
+// The writers infer the types of the columns from it, so a table that hasn't been normalized
+// has its numbers recognized in the locale l, and a normalized one in the locale it was normalized from.
+func Body(t *extract.Table, l Locale) [][]string {
+	if t.Locale != "" {
+		return t.Body()
+	}
+	return Normalized(Table(t.Body(), l))
+}
+
+// Value normalizes the text of a cell:
+// numbers are written with no thousands separator and "." as decimal separator, e.g. "-1234.56",
+// and dates are written as 2006-01-02.
+// Currency symbols and percent signs are kept. Text that is neither is returned as is.
+func Value(s string, l Locale) string {
+	trimmed := strings.TrimSpace(s)
+	if d, ok := Date(trimmed, l); ok {
+		return d.Format("2006-01-02")
+	}
+	if n, ok := Number(trimmed, l); ok {
+		return n
+	}
+	return s
+}
+
+var numberRegexp = regexp.MustCompile(`^(\$|€|£|¥|USD|EUR|GBP|NOK|SEK|DKK|CHF|kr\.?)?\s?(\d[\d.,'’ \x{00a0}\x{202f}]*)\s?(%|\$|€|£|¥|USD|EUR|GBP|NOK|SEK|DKK|CHF|kr\.?|,-)?$`)
+
+// Number returns the canonical form of a number written in the locale.
+// Negative numbers may be written with a leading or trailing minus, or in parentheses.
+func Number(s string, l Locale) (string, bool) {
+	negative := false
+	switch {
+	case strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")"):
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	case strings.HasPrefix(s, "-") || strings.HasPrefix(s, "−"):
+		negative = true
+		s = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(s, "-"), "−"))
+	case strings.HasSuffix(s, "-") && !strings.HasSuffix(s, ",-"):
+		negative = true
+		s = strings.TrimSpace(strings.TrimSuffix(s, "-"))
+	}
+	m := numberRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return "", false
+	}
+	prefix, digits, suffix := m[1], strings.TrimRight(m[2], " \u00a0\u202f"), m[3]
+	if suffix == ",-" {
+		// e.g. "100,-" is a whole amount in Norway and Germany
+		suffix = ""
+	}
+
+	integer := digits
+	fraction := ""
+	if i := strings.LastIndex(digits, l.Decimal); i >= 0 {
+		integer = digits[:i]
+		fraction = digits[i+len(l.Decimal):]
+		if fraction == "" || strings.IndexFunc(fraction, notDigit) >= 0 {
+			return "", false
+		}
+	}
+	integer, ok := removeThousands(integer, l.Thousands)
+	if !ok {
+		return "", false
+	}
+
+	n := integer
+	if fraction != "" {
+		n += "." + fraction
+	}
+	if prefix != "" {
+		n = strings.TrimSpace(prefix) + n
+	}
+	if suffix != "" {
+		n += suffix
+	}
+	if negative {
+		n = "-" + n
+	}
+	return n, true
+}
+
+// removeThousands removes the thousands separators from the digits,
+// which must be in groups of three after the first group
+func removeThousands(digits string, separators []string) (string, bool) {
+	for _, sep := range separators[1:] {
+		digits = strings.ReplaceAll(digits, sep, separators[0])
+	}
+	groups := strings.Split(digits, separators[0])
+	for i, group := range groups {
+		if group == "" || strings.IndexFunc(group, notDigit) >= 0 {
+			return "", false
+		}
+		if i > 0 && len(group) != 3 {
+			return "", false
+		}
+	}
+	return strings.Join(groups, ""), true
+}
+
+func notDigit(r rune) bool {
+	return r < '0' || r > '9'
+}
+
+// Date parses a date written in the locale.
+// Dates with the year first, e.g. 2021-12-31, are the same in all locales.
+func Date(s string, l Locale) (time.Time, bool) {
+	layouts := []string{"2006-01-02", "2006/01/02", "Jan 2, 2006", "January 2, 2006", "2 Jan 2006", "2 January 2006", "02-Jan-2006", "02-Jan-06"}
+	if l.DayFirst {
+		layouts = append(layouts, "2.1.2006", "2/1/2006", "2-1-2006", "2.1.06", "2/1/06")
+	} else {
+		layouts = append(layouts, "1/2/2006", "1-2-2006", "1/2/06", "2.1.2006")
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,96 @@
+package plaintext
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/schema"
+)
+
+// MediaType of plain text
+const MediaType = "text/plain"
+
+// FromTable draws the table with box-drawing characters, with numeric columns aligned to the right
+func FromTable(table *extract.Table, l normalize.Locale) string {
+	width := table.Width()
+	if width == 0 {
+		return ""
+	}
+	lines := table.Flattened()
+	headerRows := 0
+	if table.HeaderRows > 0 && table.HeaderRows <= len(table.Rows) {
+		headerRows = 1
+	}
+	columns := schema.Infer(table.Columns, normalize.Body(table, l))
+
+	rows := make([][]string, len(lines))
+	for i, line := range lines {
+		rows[i] = make([]string, width)
+		for j, cell := range line {
+			rows[i][j] = strings.Join(strings.Fields(strings.ReplaceAll(cell, separator, "")), " ")
+		}
+	}
+	laidOut := &strings.Builder{}
+	writeTable(laidOut, rows)
+
+	s := &strings.Builder{}
+	var widths []int
+	for i, line := range strings.Split(strings.TrimSuffix(laidOut.String(), "\n"), "\n") {
+		// each line starts and ends with a separator
+		cells := strings.Split(line, separator)
+		cells = cells[1 : len(cells)-1]
+		if i == 0 {
+			widths = make([]int, len(cells))
+			for j, cell := range cells {
+				widths[j] = utf8.RuneCountInString(cell) - 2
+			}
+			writeRule(s, widths, "┌", "┬", "┐")
+		}
+		if i == headerRows && headerRows > 0 {
+			writeRule(s, widths, "├", "┼", "┤")
+		}
+		for j, cell := range cells {
+			if i >= headerRows && j < len(columns) && columns[j].Type.Numeric() {
+				// the padding is after the text, so move it in front
+				text := strings.TrimSpace(cell)
+				cells[j] = strings.Repeat(" ", utf8.RuneCountInString(cell)-utf8.RuneCountInString(text)-1) + text + " "
+			}
+		}
+		s.WriteString("│" + strings.Join(cells, "│") + "│\n")
+	}
+	writeRule(s, widths, "└", "┴", "┘")
+	return s.String()
+}
+
+// separator is put between the cells laid out by writeTable, and is removed from their text
+const separator = "\x00"
+
+// writeTable lays out the rows in columns with a tabwriter,
+// with a separator before every cell and at the end of the line
+func writeTable(wr io.Writer, rows [][]string) {
+	w := tabwriter.NewWriter(wr, 0, 0, 0, ' ', 0)
+	for _, row := range rows {
+		for _, cell := range row {
+			fmt.Fprintf(w, "%s %s \t", separator, cell)
+		}
+		fmt.Fprintf(w, "%s\n", separator)
+	}
+	w.Flush()
+}
+
+// writeRule writes a horizontal line across the columns
+func writeRule(s *strings.Builder, widths []int, left, middle, right string) {
+	s.WriteString(left)
+	for j, width := range widths {
+		if j > 0 {
+			s.WriteString(middle)
+		}
+		s.WriteString(strings.Repeat("─", width+2))
+	}
+	s.WriteString(right + "\n")
+}
@@ -0,0 +1,164 @@
+package records
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/schema"
+)
+
+// NDJSONMediaType is newline delimited JSON, with a JSON value on each line
+const NDJSONMediaType = "application/x-ndjson"
+
+// Keys returns the object keys for the columns.
+// Columns without a name are called "Column 1", "Column 2", etc.,
+// and duplicate names get a suffix, e.g. "Revenue (2)".
+func Keys(columns []string, width int) []string {
+	keys := make([]string, width)
+	seen := make(map[string]bool)
+	for j := range keys {
+		name := ""
+		if j < len(columns) {
+			name = columns[j]
+		}
+		if name == "" {
+			name = fmt.Sprintf("Column %d", j+1)
+		}
+		// a column may already be called e.g. "Revenue (2)"
+		unique := name
+		for n := 2; seen[unique]; n++ {
+			unique = fmt.Sprintf("%s (%d)", name, n)
+		}
+		seen[unique] = true
+		keys[j] = unique
+	}
+	return keys
+}
+
+// FromTable returns a JSON array with one object per row in the body of the table, keyed by the column names.
+// The keys are in the same order as the columns. If typed, the values have the inferred types of the columns.
+func FromTable(table *extract.Table, typed bool) ([]byte, error) {
+	rows := values(table, typed)
+	keys := Keys(table.Columns, width(table.Columns, rows))
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		if err := writeObject(buf, keys, row); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteString("]")
+
+	indented := &bytes.Buffer{}
+	if err := json.Indent(indented, buf.Bytes(), "", "  "); err != nil {
+		return nil, fmt.Errorf("indent: %w", err)
+	}
+	return indented.Bytes(), nil
+}
+
+// NDJSON returns one JSON object per line for each row, keyed by the column names,
+// for loading into e.g. a data lake. If typed, the values have the inferred types of the columns.
+func NDJSON(table *extract.Table, typed bool) ([]byte, error) {
+	rows := values(table, typed)
+	keys := Keys(table.Columns, width(table.Columns, rows))
+	buf := &bytes.Buffer{}
+	for _, row := range rows {
+		if err := writeObject(buf, keys, row); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// split is the "split" orientation of pandas, which pandas.read_json(orient="split") reads
+type split struct {
+	Columns []string        `json:"columns"`
+	Index   []int           `json:"index"`
+	Data    [][]interface{} `json:"data"`
+}
+
+// Split returns a JSON object with the column names in columns, the row numbers in index,
+// and the rows in data, as in the "split" orientation of pandas.
+// If typed, the values have the inferred types of the columns.
+func Split(table *extract.Table, typed bool) ([]byte, error) {
+	rows := values(table, typed)
+	w := width(table.Columns, rows)
+	s := split{Columns: Keys(table.Columns, w), Index: make([]int, len(rows)), Data: make([][]interface{}, len(rows))}
+	for i, row := range rows {
+		s.Index[i] = i
+		// every row has a value for every column
+		s.Data[i] = make([]interface{}, w)
+		for j := range s.Data[i] {
+			s.Data[i][j] = ""
+			if j < len(row) {
+				s.Data[i][j] = row[j]
+			}
+		}
+	}
+	bs, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal: %w", err)
+	}
+	return bs, nil
+}
+
+// values returns the rows in the body of the table as values,
+// which are of the inferred type of the column if typed, or else the text
+func values(table *extract.Table, typed bool) [][]interface{} {
+	rows := table.Body()
+	if typed {
+		return schema.Values(schema.Infer(table.Columns, rows), rows)
+	}
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = make([]interface{}, len(row))
+		for j, cell := range row {
+			values[i][j] = cell
+		}
+	}
+	return values
+}
+
+// width is the number of columns, or cells in the widest row if there are more
+func width(columns []string, rows [][]interface{}) int {
+	w := len(columns)
+	for _, row := range rows {
+		if len(row) > w {
+			w = len(row)
+		}
+	}
+	return w
+}
+
+func writeObject(buf *bytes.Buffer, keys []string, row []interface{}) error {
+	buf.WriteString("{")
+	for j, key := range keys {
+		if j > 0 {
+			buf.WriteString(",")
+		}
+		var value interface{} = ""
+		if j < len(row) {
+			value = row[j]
+		}
+		keyBytes, err := json.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("marshal key: %w", err)
+		}
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("marshal value: %w", err)
+		}
+		buf.Write(keyBytes)
+		buf.WriteString(":")
+		buf.Write(valueBytes)
+	}
+	buf.WriteString("}")
+	return nil
+}
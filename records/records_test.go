@@ -0,0 +1,92 @@
+package records
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vegarsti/extract"
+)
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		width   int
+		want    []string
+	}{
+		{
+			name:    "unique",
+			columns: []string{"Name", "Revenue"},
+			width:   2,
+			want:    []string{"Name", "Revenue"},
+		},
+		{
+			name:    "unnamed",
+			columns: []string{"Name", ""},
+			width:   3,
+			want:    []string{"Name", "Column 2", "Column 3"},
+		},
+		{
+			name:    "duplicates",
+			columns: []string{"A", "A", "A"},
+			width:   3,
+			want:    []string{"A", "A (2)", "A (3)"},
+		},
+		{
+			name:    "duplicate of a suffix",
+			columns: []string{"A", "A", "A (2)"},
+			width:   3,
+			want:    []string{"A", "A (2)", "A (2) (2)"},
+		},
+		{
+			name:    "suffix before duplicate",
+			columns: []string{"A (2)", "A", "A"},
+			width:   3,
+			want:    []string{"A (2)", "A", "A (3)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Keys(tt.columns, tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	table := extract.NewTable([][]string{
+		{"Item", "Amount"},
+		{"Rent", "1200"},
+		{"Food", "300"},
+	})
+	table.Columns = []string{"Item", "Amount"}
+	table.HeaderRows = 1
+	tests := []struct {
+		name  string
+		typed bool
+		want  string
+	}{
+		{
+			name:  "text",
+			typed: false,
+			want:  "{\"Item\":\"Rent\",\"Amount\":\"1200\"}\n{\"Item\":\"Food\",\"Amount\":\"300\"}\n",
+		},
+		{
+			name:  "typed",
+			typed: true,
+			want:  "{\"Item\":\"Rent\",\"Amount\":1200}\n{\"Item\":\"Food\",\"Amount\":300}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NDJSON(table, tt.typed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -80,7 +80,8 @@ func UploadHTML(identifier string, data []byte) error {
 	uploader := s3manager.NewUploader(sess)
 	contentType := "text/html"
 	contentDisposition := "inline"
-	cacheControl := "max-age=3600"
+	// the page changes when the table is corrected
+	cacheControl := "no-cache"
 	uploadParams := &s3manager.UploadInput{
 		Bucket:             aws.String("results.extract-table.com"),
 		Key:                aws.String(identifier),
@@ -94,3 +95,24 @@ func UploadHTML(identifier string, data []byte) error {
 	}
 	return nil
 }
+
+func UploadJSON(identifier string, data []byte) error {
+	sess, err := session.NewSession()
+	if err != nil {
+		return fmt.Errorf("unable to create session: %w", err)
+	}
+	uploader := s3manager.NewUploader(sess)
+	contentType := "application/json"
+	contentDisposition := fmt.Sprintf(`attachment; filename="%s.json"`, identifier)
+	uploadParams := &s3manager.UploadInput{
+		Bucket:             aws.String("results.extract-table.com"),
+		Key:                aws.String(identifier + ".json"),
+		Body:               bytes.NewReader(data),
+		ContentDisposition: &contentDisposition,
+		ContentType:        &contentType,
+	}
+	if _, err := uploader.Upload(uploadParams); err != nil {
+		return fmt.Errorf("uploadJSON: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package schema
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Type is the type of the values in a column
+type Type string
+
+const Integer = Type("integer")
+const Decimal = Type("decimal")
+const Currency = Type("currency")
+const Percent = Type("percent")
+const Date = Type("date")
+const Text = Type("text")
+const Boolean = Type("boolean")
+
+// Numeric is true for the types of numbers, which are aligned to the right
+func (t Type) Numeric() bool {
+	return t == Integer || t == Decimal || t == Currency || t == Percent
+}
+
+// Threshold is the fraction of non-empty cells in a column
+// that must have a type for the column to get that type
+const Threshold = 0.8
+
+// Column is the inferred type of a column.
+// Confidence is the fraction of non-empty cells in the column that have the type.
+type Column struct {
+	Name       string  `json:"name"`
+	Type       Type    `json:"type"`
+	Confidence float64 `json:"confidence"`
+}
+
+var integerRegexp = regexp.MustCompile(`^[-+]?(\d{1,3}(,\d{3})+|\d+)$`)
+var decimalRegexp = regexp.MustCompile(`^[-+]?(\d{1,3}(,\d{3})+|\d+)?\.\d+$`)
+var currencyRegexp = regexp.MustCompile(`^([-+]?)(\$|€|£|¥|USD|EUR|GBP|NOK|SEK|DKK|CHF|kr\.?)?\s?([-+]?[\d.,]+)\s?(\$|€|£|¥|USD|EUR|GBP|NOK|SEK|DKK|CHF|kr\.?)?$`)
+
+// dateLayouts are the date formats that are recognized, in order of preference
+var dateLayouts = []string{
+	"2006-01-02",
+	"01/02/2006",
+	"1/2/2006",
+	"01/02/06",
+	"02.01.2006",
+	"2.1.2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"02-Jan-2006",
+	"02-Jan-06",
+}
+
+// ParseNumber parses integers and decimals, with comma as thousands separator,
+// e.g. "1,234.56"
+func ParseNumber(s string) (float64, bool) {
+	if !integerRegexp.MatchString(s) && !decimalRegexp.MatchString(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	return f, true
+}
+
+// ParseCurrency parses a number with a currency symbol or code before or after it, e.g. "$1,234.56"
+func ParseCurrency(s string) (float64, bool) {
+	m := currencyRegexp.FindStringSubmatch(s)
+	if m == nil || (m[2] == "" && m[4] == "") || (m[2] != "" && m[4] != "") {
+		return 0, false
+	}
+	f, ok := ParseNumber(m[3])
+	if !ok {
+		return 0, false
+	}
+	if m[1] == "-" {
+		f = -f
+	}
+	return f, true
+}
+
+// ParsePercent parses a number followed by a percent sign, e.g. "12.5%" is 12.5
+func ParsePercent(s string) (float64, bool) {
+	if !strings.HasSuffix(s, "%") {
+		return 0, false
+	}
+	return ParseNumber(strings.TrimSpace(strings.TrimSuffix(s, "%")))
+}
+
+// ParseDate parses a date in one of the recognized formats
+func ParseDate(s string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// is returns true if the text is a value of the type.
+// Numbers without a symbol are also currencies and percentages.
+func is(t Type, s string) bool {
+	switch t {
+	case Integer:
+		return integerRegexp.MatchString(s)
+	case Decimal:
+		_, ok := ParseNumber(s)
+		return ok
+	case Currency:
+		_, isCurrency := ParseCurrency(s)
+		_, isNumber := ParseNumber(s)
+		return isCurrency || isNumber
+	case Percent:
+		_, isPercent := ParsePercent(s)
+		_, isNumber := ParseNumber(s)
+		return isPercent || isNumber
+	case Date:
+		_, ok := ParseDate(s)
+		return ok
+	case Boolean:
+		_, err := strconv.ParseBool(s)
+		return err == nil && !integerRegexp.MatchString(s)
+	}
+	return true
+}
+
+// marked returns true if the text has the symbol of the type
+func marked(t Type, s string) bool {
+	switch t {
+	case Currency:
+		_, ok := ParseCurrency(s)
+		return ok
+	case Percent:
+		_, ok := ParsePercent(s)
+		return ok
+	}
+	return true
+}
+
+// types in order of preference: the first type with enough cells wins
+var types = []Type{Integer, Decimal, Percent, Currency, Date, Boolean}
+
+// Infer the type of each column from the rows below the header.
+// columns are the names of the columns.
+func Infer(columns []string, rows [][]string) []Column {
+	width := len(columns)
+	for _, row := range rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	schema := make([]Column, width)
+	for j := range schema {
+		if j < len(columns) {
+			schema[j].Name = columns[j]
+		}
+		cells := make([]string, 0)
+		for _, row := range rows {
+			if j < len(row) && strings.TrimSpace(row[j]) != "" {
+				cells = append(cells, strings.TrimSpace(row[j]))
+			}
+		}
+		schema[j].Type, schema[j].Confidence = infer(cells)
+	}
+	return schema
+}
+
+func infer(cells []string) (Type, float64) {
+	if len(cells) == 0 {
+		return Text, 0
+	}
+	best := 0.0
+	for _, t := range types {
+		matches := 0
+		hasMark := false
+		for _, cell := range cells {
+			if is(t, cell) {
+				matches++
+			}
+			hasMark = hasMark || marked(t, cell)
+		}
+		confidence := float64(matches) / float64(len(cells))
+		if confidence > best {
+			best = confidence
+		}
+		if hasMark && confidence >= Threshold {
+			return t, confidence
+		}
+	}
+	return Text, 1 - best
+}
+
+// Value returns the value of the text as the type:
+// a number for integers, decimals, currencies and percentages,
+// a date on the form 2006-01-02 for dates, true or false for booleans
+// (e.g. checkboxes), and nil if the text is empty.
+// Text that isn't of the type is returned as is.
+func Value(t Type, s string) interface{} {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	switch t {
+	case Integer:
+		if f, ok := ParseNumber(s); ok {
+			// a column is an integer column if most of its cells are, so the rest keep their decimals
+			if f != math.Trunc(f) {
+				return f
+			}
+			return int64(f)
+		}
+	case Decimal:
+		if f, ok := ParseNumber(s); ok {
+			return f
+		}
+	case Currency:
+		if f, ok := ParseCurrency(s); ok {
+			return f
+		}
+		if f, ok := ParseNumber(s); ok {
+			return f
+		}
+	case Percent:
+		if f, ok := ParsePercent(s); ok {
+			return f
+		}
+		if f, ok := ParseNumber(s); ok {
+			return f
+		}
+	case Date:
+		if d, ok := ParseDate(s); ok {
+			return d.Format("2006-01-02")
+		}
+	case Boolean:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// Values converts the text in each cell to the type of its column
+func Values(schema []Column, rows [][]string) [][]interface{} {
+	values := make([][]interface{}, len(rows))
+	for i, row := range rows {
+		values[i] = make([]interface{}, len(row))
+		for j, cell := range row {
+			t := Text
+			if j < len(schema) {
+				t = schema[j].Type
+			}
+			values[i][j] = Value(t, cell)
+		}
+	}
+	return values
+}
+
+// TypedTable is a table with the inferred schema,
+// and rows where the values have the type of their column.
+// If the text was normalized, Raw has the text before normalization,
+// and Locale is the locale it was written in.
+type TypedTable struct {
+	Schema []Column        `json:"schema"`
+	Rows   [][]interface{} `json:"rows"`
+	Locale string          `json:"locale,omitempty"`
+	Raw    [][]string      `json:"raw,omitempty"`
+}
+
+// Typed returns the schema and the rows with typed values
+func Typed(schema []Column, rows [][]string) *TypedTable {
+	return &TypedTable{
+		Schema: schema,
+		Rows:   Values(schema, rows),
+	}
+}
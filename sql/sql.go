@@ -0,0 +1,256 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/schema"
+)
+
+// MediaType of SQL
+const MediaType = "application/sql"
+
+// Dialect of SQL, which decides the column types and how names and values are quoted
+type Dialect string
+
+const PostgreSQL = Dialect("postgresql")
+const SQLite = Dialect("sqlite")
+const MySQL = Dialect("mysql")
+
+// BatchSize is the largest number of rows in an INSERT statement.
+// SQLite has a limit of 500 by default.
+const BatchSize = 500
+
+// MaxNameLength is the longest name of a table or column in all the dialects (PostgreSQL's limit)
+const MaxNameLength = 63
+
+// Options for the SQL statements
+type Options struct {
+	Dialect Dialect
+	// Table is the name of the table to create, which is sanitized like the column names
+	Table string
+}
+
+// Default creates a PostgreSQL table called extracted_table
+var Default = Options{Dialect: PostgreSQL, Table: "extracted_table"}
+
+// ParseOptions parses the parameters of the options, starting from o:
+// dialect (postgresql, sqlite or mysql) and table_name
+func ParseOptions(o Options, params map[string]string) (Options, error) {
+	if v, ok := params["dialect"]; ok {
+		switch d := Dialect(strings.ToLower(v)); d {
+		case PostgreSQL, SQLite, MySQL:
+			o.Dialect = d
+		case "postgres":
+			o.Dialect = PostgreSQL
+		default:
+			return Options{}, fmt.Errorf("invalid value for dialect: '%s', must be either postgresql, sqlite or mysql", v)
+		}
+	}
+	if v, ok := params["table_name"]; ok {
+		o.Table = v
+	}
+	return o, nil
+}
+
+// FromTable returns a CREATE TABLE statement for the table, and INSERT statements with its body BatchSize rows at a time
+func FromTable(table *extract.Table, o Options, l normalize.Locale) string {
+	width := table.Width()
+	if width == 0 {
+		return ""
+	}
+	body := normalize.Body(table, l)
+	columns := schema.Infer(table.Columns, body)
+	values := schema.Values(columns, body)
+	// integer columns can have a few cells with decimals, which would be rounded in an integer column
+	for _, row := range values {
+		for j, value := range row {
+			if _, ok := value.(float64); ok && j < len(columns) && columns[j].Type == schema.Integer {
+				columns[j].Type = schema.Decimal
+			}
+		}
+	}
+
+	tableName := quoteName(o.Dialect, Names([]string{o.Table}, 1)[0])
+	names := Names(table.Columns, width)
+	quoted := make([]string, width)
+	for j, name := range names {
+		quoted[j] = quoteName(o.Dialect, name)
+	}
+
+	s := &strings.Builder{}
+	fmt.Fprintf(s, "CREATE TABLE %s (\n", tableName)
+	for j := range names {
+		t := schema.Text
+		if j < len(columns) {
+			t = columns[j].Type
+		}
+		separator := ","
+		if j == width-1 {
+			separator = ""
+		}
+		fmt.Fprintf(s, "  %s %s%s\n", quoted[j], columnType(o.Dialect, t), separator)
+	}
+	s.WriteString(");\n")
+
+	for start := 0; start < len(values); start += BatchSize {
+		end := start + BatchSize
+		if end > len(values) {
+			end = len(values)
+		}
+		fmt.Fprintf(s, "INSERT INTO %s (%s) VALUES\n", tableName, strings.Join(quoted, ", "))
+		for i, row := range values[start:end] {
+			literals := make([]string, width)
+			for j := range literals {
+				var value interface{}
+				if j < len(row) {
+					value = row[j]
+				}
+				// text that isn't of the type of its column, e.g. "n/a" in a column
+				// of numbers, would fail the insert
+				if j < len(columns) && !fits(columns[j].Type, value) {
+					value = nil
+				}
+				literals[j] = literal(o.Dialect, value)
+			}
+			separator := ","
+			if start+i == end-1 {
+				separator = ";"
+			}
+			fmt.Fprintf(s, "  (%s)%s\n", strings.Join(literals, ", "), separator)
+		}
+	}
+	return s.String()
+}
+
+// Names returns names of the columns that can be used in SQL: lower case letters, digits and
+// underscores, not starting with a digit and at most MaxNameLength long.
+// Columns without a name are called column_1, column_2, etc.,
+// and duplicate names get a suffix, e.g. revenue_2.
+func Names(columns []string, width int) []string {
+	names := make([]string, width)
+	seen := make(map[string]bool)
+	for j := range names {
+		name := ""
+		if j < len(columns) {
+			name = sanitize(columns[j])
+		}
+		if name == "" {
+			name = fmt.Sprintf("column_%d", j+1)
+		}
+		unique := name
+		for n := 2; seen[unique]; n++ {
+			suffix := fmt.Sprintf("_%d", n)
+			unique = truncate(name, MaxNameLength-len(suffix)) + suffix
+		}
+		seen[unique] = true
+		names[j] = unique
+	}
+	return names
+}
+
+// sanitize replaces everything but letters and digits with underscores
+func sanitize(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	name = strings.Join(words, "_")
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "c_" + name
+	}
+	return truncate(name, MaxNameLength)
+}
+
+// truncate to at most n bytes, without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// quoteName quotes a table or column name, so it can't be mistaken for a keyword
+func quoteName(d Dialect, name string) string {
+	if d == MySQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func columnType(d Dialect, t schema.Type) string {
+	switch t {
+	case schema.Integer:
+		if d == SQLite {
+			return "INTEGER"
+		}
+		return "BIGINT"
+	case schema.Decimal, schema.Currency, schema.Percent:
+		// MySQL's DECIMAL has no decimals unless given a precision
+		if d == MySQL {
+			return "DOUBLE"
+		}
+		return "NUMERIC"
+	case schema.Date:
+		// SQLite has no date type, but dates are written as 2006-01-02 so they sort
+		if d == SQLite {
+			return "TEXT"
+		}
+		return "DATE"
+	case schema.Boolean:
+		if d == SQLite {
+			return "INTEGER"
+		}
+		return "BOOLEAN"
+	}
+	return "TEXT"
+}
+
+// fits is true if the value can be inserted in a column of the type
+func fits(t schema.Type, value interface{}) bool {
+	s, ok := value.(string)
+	if !ok || t == schema.Text {
+		return true
+	}
+	if t == schema.Date {
+		_, err := time.Parse("2006-01-02", s)
+		return err == nil
+	}
+	return false
+}
+
+// literal returns the value as an SQL literal
+func literal(d Dialect, value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		if d == SQLite {
+			if v {
+				return "1"
+			}
+			return "0"
+		}
+		return strings.ToUpper(strconv.FormatBool(v))
+	}
+	// PostgreSQL doesn't allow NUL in text
+	s := strings.ReplaceAll(fmt.Sprint(value), "\x00", "")
+	s = strings.ReplaceAll(s, "'", "''")
+	// MySQL also uses backslash to escape characters in strings
+	if d == MySQL {
+		s = strings.ReplaceAll(s, `\`, `\\`)
+	}
+	return "'" + s + "'"
+}
@@ -0,0 +1,179 @@
+package stitch
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/header"
+)
+
+// Table is a table that may continue across several pages
+type Table struct {
+	// Rows of cells, in the columns of the first page of the table
+	Rows [][]box.Box
+	// Pages is the page number of each row
+	Pages []int
+}
+
+// Strings returns the content of the cells
+func (t *Table) Strings() [][]string {
+	lines := make([][]string, len(t.Rows))
+	for i := range t.Rows {
+		lines[i] = make([]string, len(t.Rows[i]))
+		for j := range t.Rows[i] {
+			lines[i][j] = t.Rows[i][j].Content
+		}
+	}
+	return lines
+}
+
+// Prune removes empty rows and columns and merges sparse columns, see box.Prune,
+// and returns the decisions that were made
+func (t *Table) Prune() []string {
+	rows, kept, decisions := box.Prune(t.Rows)
+	pages := make([]int, len(kept))
+	for k, i := range kept {
+		pages[k] = t.Pages[i]
+	}
+	t.Rows, t.Pages = rows, pages
+	return decisions
+}
+
+// Stitch finds a table on each page of boxes, and stitches together tables that
+// continue on the next page. A page is a continuation if its words fit the column
+// layout of the first page of the table, once header rows repeated at the top are dropped.
+// The words on continuation pages are put in the columns of the first page.
+func Stitch(boxes []box.Box) []*Table {
+	tables := make([]*Table, 0)
+	var current *Table
+	var columns [][]float64
+	var headerRows []string
+	for _, page := range box.Pages(boxes) {
+		onPage := box.OnPage(boxes, page)
+		var rows [][]box.Box
+		body := dropRepeatedHeader(onPage, headerRows)
+		if current != nil && continues(columns, body) {
+			rows = Align(columns, body)
+		} else {
+			rows, _ = box.ToTable(onPage)
+			current = &Table{}
+			tables = append(tables, current)
+			columns = columnRegions(rows)
+			n := header.Detect(rows)
+			headerRows = make([]string, n)
+			for i := range headerRows {
+				headerRows[i] = rowText(rows[i])
+			}
+		}
+		for i := range rows {
+			for j := range rows[i] {
+				rows[i][j].Page = page
+			}
+			current.Rows = append(current.Rows, rows[i])
+			current.Pages = append(current.Pages, page)
+		}
+	}
+	return tables
+}
+
+// Align puts the boxes in the given column regions,
+// and in rows by finding regions in the y direction
+func Align(columns [][]float64, boxes []box.Box) [][]box.Box {
+	rows := box.CartesianProduct(columns, box.RowRegions(boxes))
+	box.AssignNearest(rows, boxes)
+	return rows
+}
+
+// AddPageColumn adds a column first in the table with the page number of each row.
+// The name of the column, "Page", is put in the last of the header rows.
+func AddPageColumn(table *extract.Table, pages []int) {
+	values := make([]string, 0)
+	for i := table.HeaderRows; i < len(pages); i++ {
+		values = append(values, strconv.Itoa(pages[i]))
+	}
+	table.InsertColumn(0, "Page", values)
+}
+
+// columnRegions returns the x regions of the columns of a table
+func columnRegions(rows [][]box.Box) [][]float64 {
+	if len(rows) == 0 {
+		return nil
+	}
+	columns := make([][]float64, len(rows[0]))
+	for j, cell := range rows[0] {
+		columns[j] = []float64{cell.XLeft, cell.XRight}
+	}
+	return columns
+}
+
+// continues is true if the boxes fit the column layout:
+// no region in the x direction of the boxes overlaps with more than one column,
+// and at least half the columns are in use.
+// Header words spanning several columns are left out, as in box.ToTable, and regions
+// that match no column, such as a page number in the margin, are allowed.
+func continues(columns [][]float64, boxes []box.Box) bool {
+	if len(columns) == 0 {
+		return false
+	}
+	regular, _ := box.SplitSpanning(boxes, box.RowRegions(boxes))
+	used := make(map[int]bool)
+	for _, region := range box.XRegions(regular) {
+		overlaps := 0
+		for j, column := range columns {
+			if region[0] <= column[1] && region[1] >= column[0] {
+				overlaps++
+				used[j] = true
+			}
+		}
+		if overlaps > 1 {
+			return false
+		}
+	}
+	return 2*len(used) >= len(columns)
+}
+
+// dropRepeatedHeader removes the boxes of the header rows if they're repeated at the top of the page
+func dropRepeatedHeader(boxes []box.Box, headerRows []string) []box.Box {
+	regions := box.RowRegions(boxes)
+	if len(headerRows) == 0 || len(regions) <= len(headerRows) {
+		return boxes
+	}
+	for i, text := range headerRows {
+		if rowText(inRegion(boxes, regions[i])) != text {
+			return boxes
+		}
+	}
+	bottom := regions[len(headerRows)-1][1]
+	body := make([]box.Box, 0)
+	for _, b := range boxes {
+		if b.Rect().Center().Y > bottom {
+			body = append(body, b)
+		}
+	}
+	return body
+}
+
+// inRegion returns the boxes with their center in the region in the y direction
+func inRegion(boxes []box.Box, region []float64) []box.Box {
+	in := make([]box.Box, 0)
+	for _, b := range boxes {
+		if y := b.Rect().Center().Y; y >= region[0] && y <= region[1] {
+			in = append(in, b)
+		}
+	}
+	return in
+}
+
+// rowText is the words in the row, for comparing rows.
+// The words are sorted, so the order they were read in doesn't matter.
+func rowText(row []box.Box) string {
+	words := make([]string, 0)
+	for _, cell := range row {
+		words = append(words, strings.Fields(strings.ToLower(cell.Content))...)
+	}
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
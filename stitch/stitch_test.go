@@ -0,0 +1,97 @@
+package stitch
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/vegarsti/extract/box"
+)
+
+// word returns a box on row i of the page
+func word(text string, page int, i int, left, right float64) box.Box {
+	top := 0.1 + float64(i)*0.05
+	return box.Box{Content: text, XLeft: left, XRight: right, YTop: top, YBottom: top + 0.02, Page: page}
+}
+
+// transactions is a page of a list of transactions, with "2020" over "Revenue" and "Cost"
+// if stacked, and a page number in the margin if numbered
+func transactions(page int, stacked bool, numbered bool) []box.Box {
+	boxes := make([]box.Box, 0)
+	i := 0
+	if stacked {
+		boxes = append(boxes, word("2020", page, i, 0.45, 0.65))
+		i++
+		boxes = append(boxes, word("Name", page, i, 0.1, 0.2), word("Revenue", page, i, 0.4, 0.5), word("Cost", page, i, 0.6, 0.7))
+	} else {
+		boxes = append(boxes, word("Name", page, i, 0.1, 0.2), word("Revenue", page, i, 0.4, 0.5), word("Cost", page, i, 0.6, 0.7))
+	}
+	i++
+	for k := 0; k < 5; k++ {
+		boxes = append(boxes,
+			word(fmt.Sprintf("Item%d", k), page, i, 0.1, 0.25),
+			word(fmt.Sprintf("%d00", k+1), page, i, 0.42, 0.5),
+			word(fmt.Sprintf("%d0", k+1), page, i, 0.64, 0.7),
+		)
+		i++
+	}
+	if numbered {
+		boxes = append(boxes, word(fmt.Sprint(page), page, i, 0.9, 0.92))
+	}
+	return boxes
+}
+
+// letter is a page of running text in a single column
+func letter(page int) []box.Box {
+	boxes := make([]box.Box, 0)
+	for i := 0; i < 6; i++ {
+		boxes = append(boxes, word("Dear customer, thank you for your order", page, i, 0.1, 0.9))
+	}
+	return boxes
+}
+
+func TestStitch(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages [][]box.Box
+		// rows is the number of rows in each table
+		rows []int
+	}{
+		{
+			name:  "repeated header",
+			pages: [][]box.Box{transactions(1, false, false), transactions(2, false, false)},
+			rows:  []int{11},
+		},
+		{
+			name:  "repeated stacked header",
+			pages: [][]box.Box{transactions(1, true, false), transactions(2, true, false)},
+			rows:  []int{12},
+		},
+		{
+			name:  "page numbers",
+			pages: [][]box.Box{transactions(1, true, true), transactions(2, true, true), transactions(3, true, true)},
+			// the header once, and the rows and the page number of each page
+			rows: []int{2 + 3*6},
+		},
+		{
+			name:  "another layout",
+			pages: [][]box.Box{transactions(1, false, false), letter(2)},
+			rows:  []int{6, 6},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boxes := make([]box.Box, 0)
+			for _, page := range tt.pages {
+				boxes = append(boxes, page...)
+			}
+			tables := Stitch(boxes)
+			rows := make([]int, len(tables))
+			for k, table := range tables {
+				rows[k] = len(table.Rows)
+			}
+			if fmt.Sprint(rows) != fmt.Sprint(tt.rows) {
+				t.Errorf("got tables with %v rows, want %v", rows, tt.rows)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package extract
+
+import (
+	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/geometry"
+)
+
+// Cell is a cell in a table, with where it was found in the document
+type Cell struct {
+	Text string `json:"text"`
+	// Rect is the region of the cell on the page
+	Rect geometry.Rect `json:"rect"`
+	// Page is the page of the document the cell is on, starting at 1
+	Page int `json:"page"`
+	// Confidence is the lowest confidence of the OCR of the words in the cell, from 0 to 100,
+	// or 0 if unknown
+	Confidence float64 `json:"confidence"`
+	// RowSpan and ColumnSpan are the number of rows and columns the cell covers
+	RowSpan    int `json:"row_span"`
+	ColumnSpan int `json:"column_span"`
+	// Selected is set for selection elements such as checkboxes, and is true if it's ticked
+	Selected *bool `json:"selected,omitempty"`
+	// Raw is the text as it was extracted, if Text has been corrected or normalized since
+	Raw string `json:"raw,omitempty"`
+}
+
+// Table is an extracted table. It is encoded to and from JSON without losing anything.
+type Table struct {
+	Rows [][]Cell `json:"rows"`
+	// Columns are the names of the columns, with the header rows flattened,
+	// e.g. "2020 / Revenue"
+	Columns []string `json:"columns"`
+	// HeaderRows is the number of rows at the top of the table that make up the header
+	HeaderRows int `json:"header_rows"`
+	// Pages are the pages of the document the table is on
+	Pages []int `json:"pages"`
+	// Direction is "rtl" if the first column is to the right, and "ltr" otherwise
+	Direction string `json:"direction"`
+	// Locale is the locale numbers and dates were normalized from, if they were
+	Locale string `json:"locale"`
+}
+
+// NewTable returns a table of the text in rows, without a header or positions
+func NewTable(rows [][]string) *Table {
+	t := &Table{
+		Rows:      make([][]Cell, len(rows)),
+		Columns:   make([]string, 0),
+		Pages:     make([]int, 0),
+		Direction: "ltr",
+	}
+	for i, row := range rows {
+		t.Rows[i] = make([]Cell, len(row))
+		for j, text := range row {
+			t.Rows[i][j] = Cell{Text: text, RowSpan: 1, ColumnSpan: 1}
+		}
+	}
+	return t
+}
+
+// CellFromBox returns the box as a cell in a table
+func CellFromBox(b box.Box) Cell {
+	return Cell{
+		Text:       b.Content,
+		Rect:       b.Rect(),
+		Page:       b.Page,
+		Confidence: b.Confidence,
+		RowSpan:    1,
+		ColumnSpan: 1,
+		Selected:   b.Selected,
+	}
+}
+
+// CellsFromBoxes returns the rows of boxes as rows of cells in a table
+func CellsFromBoxes(rows [][]box.Box) [][]Cell {
+	cells := make([][]Cell, len(rows))
+	for i := range rows {
+		cells[i] = make([]Cell, len(rows[i]))
+		for j, b := range rows[i] {
+			cells[i][j] = CellFromBox(b)
+		}
+	}
+	return cells
+}
+
+// BoxesFromCells returns the rows of cells in a table as rows of boxes,
+// e.g. to measure the indentation of a table that has been edited
+func BoxesFromCells(rows [][]Cell) [][]box.Box {
+	boxes := make([][]box.Box, len(rows))
+	for i := range rows {
+		boxes[i] = make([]box.Box, len(rows[i]))
+		for j, cell := range rows[i] {
+			b := box.FromRect(cell.Rect)
+			b.Content = cell.Text
+			b.Page = cell.Page
+			b.Confidence = cell.Confidence
+			b.Selected = cell.Selected
+			boxes[i][j] = b
+		}
+	}
+	return boxes
+}
+
+// Copy returns a copy of the table that can be changed without changing t
+func (t *Table) Copy() *Table {
+	c := *t
+	c.Rows = make([][]Cell, len(t.Rows))
+	for i := range t.Rows {
+		c.Rows[i] = append([]Cell{}, t.Rows[i]...)
+	}
+	c.Columns = append([]string{}, t.Columns...)
+	c.Pages = append([]int{}, t.Pages...)
+	return &c
+}
+
+// RowPages returns the page of each row: the page of the first cell in the row that is on a page,
+// or the page of the row above if none are, e.g. for a row that was added after the table was extracted
+func (t *Table) RowPages() []int {
+	pages := make([]int, len(t.Rows))
+	page := 0
+	for i, row := range t.Rows {
+		for _, cell := range row {
+			if cell.Page > 0 {
+				page = cell.Page
+				break
+			}
+		}
+		pages[i] = page
+	}
+	return pages
+}
+
+// Strings returns the text of the cells
+func (t *Table) Strings() [][]string {
+	lines := make([][]string, len(t.Rows))
+	for i := range t.Rows {
+		lines[i] = make([]string, len(t.Rows[i]))
+		for j := range t.Rows[i] {
+			lines[i][j] = t.Rows[i][j].Text
+		}
+	}
+	return lines
+}
+
+// Flattened returns the text of the cells, with the header rows replaced by the column names
+func (t *Table) Flattened() [][]string {
+	lines := t.Strings()
+	if t.HeaderRows == 0 || t.HeaderRows > len(lines) {
+		return lines
+	}
+	return append([][]string{t.Columns}, lines[t.HeaderRows:]...)
+}
+
+// Body returns the text of the cells below the header
+func (t *Table) Body() [][]string {
+	if t.HeaderRows > len(t.Rows) {
+		return nil
+	}
+	return t.Strings()[t.HeaderRows:]
+}
+
+// SetBody replaces the text of the cells below the header, keeping their positions
+// and the text they were extracted with
+func (t *Table) SetBody(body [][]string) {
+	for i, row := range body {
+		for j, text := range row {
+			cell := &t.Rows[t.HeaderRows+i][j]
+			if cell.Raw == "" && text != cell.Text {
+				cell.Raw = cell.Text
+			}
+			cell.Text = text
+		}
+	}
+}
+
+// Width is the number of columns, i.e. the number of cells in the widest row
+func (t *Table) Width() int {
+	width := len(t.Columns)
+	for _, row := range t.Rows {
+		if len(row) > width {
+			width = len(row)
+		}
+	}
+	return width
+}
+
+// AddColumn adds a column last in the table, see InsertColumn
+func (t *Table) AddColumn(name string, values []string) {
+	t.InsertColumn(t.Width(), name, values)
+}
+
+// InsertColumn inserts a column at index j, with values for the rows below the header.
+// The name of the column is put in the last header row.
+func (t *Table) InsertColumn(j int, name string, values []string) {
+	width := t.Width()
+	for len(t.Columns) < width {
+		t.Columns = append(t.Columns, "")
+	}
+	t.Columns = append(t.Columns[:j], append([]string{name}, t.Columns[j:]...)...)
+	for i := range t.Rows {
+		// pad short rows so the column lines up
+		for len(t.Rows[i]) < j {
+			t.Rows[i] = append(t.Rows[i], Cell{RowSpan: 1, ColumnSpan: 1})
+		}
+		cell := Cell{RowSpan: 1, ColumnSpan: 1}
+		if len(t.Rows[i]) > 0 {
+			cell.Page = t.Rows[i][0].Page
+		}
+		if i == t.HeaderRows-1 {
+			cell.Text = name
+		}
+		if i >= t.HeaderRows {
+			cell.Text = values[i-t.HeaderRows]
+		}
+		t.Rows[i] = append(t.Rows[i][:j], append([]Cell{cell}, t.Rows[i][j:]...)...)
+	}
+}
+
+// Covered returns which cells are covered by a cell above or to the left of them that spans
+// several rows or columns. Covered cells are kept so the rows line up, but they're empty.
+func (t *Table) Covered() [][]bool {
+	covered := make([][]bool, len(t.Rows))
+	for i := range t.Rows {
+		covered[i] = make([]bool, len(t.Rows[i]))
+	}
+	for i, row := range t.Rows {
+		for j, cell := range row {
+			if covered[i][j] {
+				continue
+			}
+			for k := i; k < i+cell.RowSpan && k < len(t.Rows); k++ {
+				for l := j; l < j+cell.ColumnSpan && l < len(t.Rows[k]); l++ {
+					if k != i || l != j {
+						covered[k][l] = true
+					}
+				}
+			}
+		}
+	}
+	return covered
+}
@@ -2,8 +2,8 @@ package textract
 
 import (
 	"fmt"
-	"math"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,6 +11,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/textract"
 	"github.com/vegarsti/extract"
 	"github.com/vegarsti/extract/box"
+	"github.com/vegarsti/extract/geometry"
 	"github.com/vegarsti/extract/s3"
 )
 
@@ -72,8 +73,18 @@ func analyzePDF(file *extract.File) (*textract.AnalyzeDocumentOutput, error) {
 		}
 		processing = *getOutput.JobStatus == "IN_PROGRESS"
 	}
+	// the blocks of documents with many pages are split across several responses
+	blocks := getOutput.Blocks
+	for getOutput.NextToken != nil {
+		getInput.NextToken = getOutput.NextToken
+		getOutput, err = svc.GetDocumentAnalysis(getInput)
+		if err != nil {
+			return nil, fmt.Errorf("get document analysis: %w", err)
+		}
+		blocks = append(blocks, getOutput.Blocks...)
+	}
 	return &textract.AnalyzeDocumentOutput{
-		Blocks:           getOutput.Blocks,
+		Blocks:           blocks,
 		DocumentMetadata: getOutput.DocumentMetadata,
 	}, nil
 }
@@ -99,7 +110,7 @@ func ocrPDF(file *extract.File) (*textract.DetectDocumentTextOutput, error) {
 	}
 	startOutput, err := svc.StartDocumentTextDetection(startInput)
 	if err != nil {
-		return nil, fmt.Errorf("start document analysis: %w", err)
+		return nil, fmt.Errorf("start document text detection: %w", err)
 	}
 	getInput := &textract.GetDocumentTextDetectionInput{JobId: startOutput.JobId}
 	processing := true
@@ -108,12 +119,22 @@ func ocrPDF(file *extract.File) (*textract.DetectDocumentTextOutput, error) {
 		time.Sleep(10 * time.Millisecond)
 		getOutput, err = svc.GetDocumentTextDetection(getInput)
 		if err != nil {
-			return nil, fmt.Errorf("get document analysis: %w", err)
+			return nil, fmt.Errorf("get document text detection: %w", err)
 		}
 		processing = *getOutput.JobStatus == "IN_PROGRESS"
 	}
+	// the blocks of documents with many pages are split across several responses
+	blocks := getOutput.Blocks
+	for getOutput.NextToken != nil {
+		getInput.NextToken = getOutput.NextToken
+		getOutput, err = svc.GetDocumentTextDetection(getInput)
+		if err != nil {
+			return nil, fmt.Errorf("get document text detection: %w", err)
+		}
+		blocks = append(blocks, getOutput.Blocks...)
+	}
 	return &textract.DetectDocumentTextOutput{
-		Blocks:           getOutput.Blocks,
+		Blocks:           blocks,
 		DocumentMetadata: getOutput.DocumentMetadata,
 	}, nil
 }
@@ -212,35 +233,39 @@ func textInCellBlock(blocks map[string]*textract.Block, cell *textract.Block) st
 }
 
 func ToTableWithSplitHeuristic(output *textract.AnalyzeDocumentOutput) ([][]string, error) {
+	words := toWords(output.Blocks)
+	rows := extract.PartitionIntoRows(words)
+	splitAt := extract.FindSplits(words)
+	table := toTable(rows, splitAt, extract.SplitRowBoxesEdge)
+	return table, nil
+}
+
+// ToTableWithProjectionProfile is like ToTableWithSplitHeuristic for the output of text detection,
+// but finds the columns with extract.FindSplitsProjection and puts the words in them by the
+// alignment of each column, so right-aligned numbers that reach into the gutter stay in their column
+func ToTableWithProjectionProfile(output *textract.DetectDocumentTextOutput, minSupport float64) ([][]string, error) {
+	words := toWords(output.Blocks)
+	rows := extract.PartitionIntoRows(words)
+	splitAt := extract.FindSplitsProjection(rows, minSupport)
+	alignments := extract.DetectAlignment(rows, splitAt)
+	table := toTable(rows, splitAt, extract.SplitRowBoxesAligned(alignments))
+	return table, nil
+}
+
+// toWords converts the word blocks to words
+func toWords(blocks []*textract.Block) []extract.Word {
 	words := make([]extract.Word, 0)
-	for _, block := range output.Blocks {
+	for _, block := range blocks {
 		if *block.BlockType != "WORD" {
 			continue
 		}
-		w := extract.Word{
-			Text:  *block.Text,
-			LeftX: 1,
-			TopY:  1,
+		polygon := make([]geometry.Point, len(block.Geometry.Polygon))
+		for i, point := range block.Geometry.Polygon {
+			polygon[i] = geometry.Point{X: *point.X, Y: *point.Y}
 		}
-		/*
-			Coordinate system
-				topLeft := {x: 0, y: 0}
-				topRight := {x: 0, y: 1}
-				bottomLeft := {x: 1, y: 0}
-				bottomRight := {x: 1, y: 1}
-		*/
-		for _, boundingBox := range block.Geometry.Polygon {
-			w.LeftX = math.Min(w.LeftX, *boundingBox.X)
-			w.RightX = math.Max(w.RightX, *boundingBox.X)
-			w.TopY = math.Min(w.TopY, *boundingBox.Y)
-			w.BottomY = math.Max(w.BottomY, *boundingBox.Y)
-		}
-		words = append(words, w)
+		words = append(words, extract.NewWord(*block.Text, geometry.FromPoints(polygon)))
 	}
-	rows := extract.PartitionIntoRows(words)
-	splitAt := extract.FindSplits(words)
-	table := toTable(rows, splitAt, extract.SplitRowBoxesEdge)
-	return table, nil
+	return words
 }
 
 func toTable(rows [][]extract.Word, splitAt []float64, splitFunc func([]extract.Word, []float64) [][]extract.Word) [][]string {
@@ -254,11 +279,7 @@ func toTable(rows [][]extract.Word, splitAt []float64, splitFunc func([]extract.
 	for i, rowBoxes := range rows {
 		cellsBoxes := splitFunc(rowBoxes, splitAt)
 		for j, cell := range cellsBoxes {
-			wordsInCell := make([]string, len(cell))
-			for k, w := range cell {
-				wordsInCell[k] = w.Text
-			}
-			table[i][j] = strings.TrimSpace(strings.Join(wordsInCell, " "))
+			table[i][j] = extract.Text(cell)
 		}
 	}
 	return table
@@ -288,17 +309,40 @@ func ToBoxesFromOCR(output *textract.DetectDocumentTextOutput) ([]box.Box, error
 	// fmt.Printf("%+v", rowMap)
 	// fmt.Printf("%+v", blocks)
 
+	return toBoxes(output.Blocks), nil
+}
+
+// ToBoxesFromAnalysis converts the output of document analysis to boxes.
+// Unlike text detection, document analysis also finds selection elements such as checkboxes.
+func ToBoxesFromAnalysis(output *textract.AnalyzeDocumentOutput) ([]box.Box, error) {
+	return toBoxes(output.Blocks), nil
+}
+
+// toBoxes converts word blocks to boxes with their text,
+// and selection element blocks to boxes that are either selected or not
+func toBoxes(blocks []*textract.Block) []box.Box {
 	boxes := make([]box.Box, 0)
 	for _, cell := range blocks {
-		if *cell.BlockType != "WORD" {
+		if *cell.BlockType != "WORD" && *cell.BlockType != "SELECTION_ELEMENT" {
 			continue
 		}
-		box := box.Box{
-			XLeft:   *cell.Geometry.BoundingBox.Left,
-			XRight:  *cell.Geometry.BoundingBox.Left + *cell.Geometry.BoundingBox.Width,
-			YTop:    *cell.Geometry.BoundingBox.Top,
-			YBottom: *cell.Geometry.BoundingBox.Top + *cell.Geometry.BoundingBox.Height,
-			Content: *cell.Text,
+		// images only have one page
+		page := 1
+		if cell.Page != nil {
+			page = int(*cell.Page)
+		}
+		boundingBox := cell.Geometry.BoundingBox
+		box := box.FromRect(geometry.FromSize(*boundingBox.Left, *boundingBox.Top, *boundingBox.Width, *boundingBox.Height))
+		box.Page = page
+		if cell.Confidence != nil {
+			box.Confidence = *cell.Confidence
+		}
+		if *cell.BlockType == "SELECTION_ELEMENT" {
+			selected := cell.SelectionStatus != nil && *cell.SelectionStatus == "SELECTED"
+			box.Selected = &selected
+			box.Content = strconv.FormatBool(selected)
+		} else {
+			box.Content = *cell.Text
 		}
 		// Debug printing
 		// fmt.Printf("left: %+v\n", *cell.Geometry.BoundingBox.Left)
@@ -308,5 +352,5 @@ func ToBoxesFromOCR(output *textract.DetectDocumentTextOutput) ([]box.Box, error
 		// fmt.Printf("%+v\n", box)
 		boxes = append(boxes, box)
 	}
-	return boxes, nil
+	return boxes
 }
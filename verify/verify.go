@@ -0,0 +1,271 @@
+package verify
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vegarsti/extract/schema"
+)
+
+var totalRegexp = regexp.MustCompile(`(?i)^\s*(grand\s+)?(sub-?\s?)?(total|totals|totalt|sum|summe|gesamt|i alt)\b`)
+var subtotalRegexp = regexp.MustCompile(`(?i)^\s*(sub-?\s?total|delsum|zwischensumme)`)
+
+// Cell is a cell in the table, with the row index into the rows given to Verify
+type Cell struct {
+	Row    int    `json:"row"`
+	Column int    `json:"column"`
+	Text   string `json:"text"`
+	// Suggestion is what the text would have to be for the sum to be correct
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Mismatch is a column where the line items don't add up to the total
+type Mismatch struct {
+	Column int     `json:"column"`
+	Name   string  `json:"name"`
+	Total  float64 `json:"total"`
+	Sum    float64 `json:"sum"`
+	// Culprits are the cells that were likely read wrong,
+	// since a single wrong or swapped digit in them explains the difference
+	Culprits []Cell `json:"culprits"`
+}
+
+// Check is the verification of a total or subtotal row
+type Check struct {
+	Row      int    `json:"row"`
+	Label    string `json:"label"`
+	Subtotal bool   `json:"subtotal"`
+	// Items are the rows that are summed
+	Items      []int      `json:"items"`
+	Columns    []int      `json:"columns"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+// Report of all the total rows in a table.
+// OK is false if any total doesn't match the sum of its line items.
+type Report struct {
+	OK     bool    `json:"ok"`
+	Checks []Check `json:"checks"`
+}
+
+// totalLabel returns the label of the row if it says the row is a total.
+// The label is the first cell that isn't empty or a number, so a line item such as
+// "Total Care Plan" further out in a description column doesn't make the row a total.
+func totalLabel(row []string, skip map[int]bool) (string, bool) {
+	for j, cell := range row {
+		if skip[j] {
+			continue
+		}
+		text := strings.TrimSpace(cell)
+		if text == "" {
+			continue
+		}
+		// the text is normalized, so numbers parse
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			continue
+		}
+		if totalRegexp.MatchString(text) || subtotalRegexp.MatchString(text) {
+			return text, true
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// Verify finds total and subtotal rows by keywords such as "Total" and "Subtotal",
+// and checks that the numeric columns of their line items sum to the total.
+// A total row that isn't last in the table is a subtotal of the rows above it,
+// and the last total row is the sum of all line items.
+// The text in rows should be normalized.
+// skip are the columns that aren't part of the table as it was extracted, e.g. the page of each row,
+// which are neither labels nor summed.
+func Verify(columns []string, rows [][]string, skip []int) Report {
+	report := Report{OK: true, Checks: make([]Check, 0)}
+	skipped := make(map[int]bool)
+	for _, j := range skip {
+		skipped[j] = true
+	}
+	totals := make([]int, 0)
+	labels := make(map[int]string)
+	for i, row := range rows {
+		if label, ok := totalLabel(row, skipped); ok {
+			totals = append(totals, i)
+			labels[i] = label
+		}
+	}
+	if len(totals) == 0 {
+		return report
+	}
+
+	items := make([]int, 0)
+	for i := range rows {
+		if _, ok := labels[i]; !ok {
+			items = append(items, i)
+		}
+	}
+	summable := make(map[int]bool)
+	for j, column := range schema.Infer(columns, itemRows(rows, items)) {
+		summable[j] = !skipped[j] && column.Type == schema.Integer || column.Type == schema.Decimal || column.Type == schema.Currency
+	}
+
+	// the last total is a grand total if nothing but totals and empty rows follow it
+	last := totals[len(totals)-1]
+	grand := true
+	for i := last + 1; i < len(rows); i++ {
+		if strings.Join(rows[i], "") != "" {
+			grand = false
+		}
+	}
+
+	sectionStart := 0
+	for _, t := range totals {
+		check := Check{
+			Row:        t,
+			Label:      labels[t],
+			Subtotal:   !(grand && t == last) || subtotalRegexp.MatchString(labels[t]),
+			Items:      make([]int, 0),
+			Columns:    make([]int, 0),
+			Mismatches: make([]Mismatch, 0),
+		}
+		start := sectionStart
+		if !check.Subtotal {
+			start = 0
+		}
+		for _, i := range items {
+			if i >= start && i < t {
+				check.Items = append(check.Items, i)
+			}
+		}
+		sectionStart = t + 1
+		if len(check.Items) == 0 {
+			continue
+		}
+		for j, cell := range rows[t] {
+			if !summable[j] {
+				continue
+			}
+			total, ok := number(cell)
+			if !ok {
+				continue
+			}
+			check.Columns = append(check.Columns, j)
+			sum := 0.0
+			precision := decimals(cell)
+			for _, i := range check.Items {
+				if j >= len(rows[i]) {
+					continue
+				}
+				if f, ok := number(rows[i][j]); ok {
+					sum += f
+					if d := decimals(rows[i][j]); d > precision {
+						precision = d
+					}
+				}
+			}
+			// allow for rounding in the last decimal
+			if math.Abs(sum-total) <= math.Pow(10, -float64(precision))/2 {
+				continue
+			}
+			name := ""
+			if j < len(columns) {
+				name = columns[j]
+			}
+			check.Mismatches = append(check.Mismatches, Mismatch{
+				Column:   j,
+				Name:     name,
+				Total:    total,
+				Sum:      sum,
+				Culprits: culprits(rows, check.Items, t, j, sum-total, precision),
+			})
+		}
+		if len(check.Mismatches) > 0 {
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report
+}
+
+func itemRows(rows [][]string, items []int) [][]string {
+	result := make([][]string, len(items))
+	for k, i := range items {
+		result[k] = rows[i]
+	}
+	return result
+}
+
+// number parses a normalized number, possibly with a currency
+func number(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if f, ok := schema.ParseNumber(s); ok {
+		return f, true
+	}
+	return schema.ParseCurrency(s)
+}
+
+// decimals is the number of digits after the decimal point
+func decimals(s string) int {
+	i := strings.LastIndex(s, ".")
+	if i < 0 {
+		return 0
+	}
+	n := 0
+	for _, r := range s[i+1:] {
+		if r >= '0' && r <= '9' {
+			n++
+		}
+	}
+	return n
+}
+
+// culprits returns the cells where the difference between the sum and the total
+// is explained by a single wrong digit or two swapped digits.
+// The total cell itself is also a candidate.
+func culprits(rows [][]string, items []int, totalRow int, column int, difference float64, decimals int) []Cell {
+	cells := make([]Cell, 0)
+	candidates := append(append([]int{}, items...), totalRow)
+	for _, i := range candidates {
+		if column >= len(rows[i]) {
+			continue
+		}
+		f, ok := number(rows[i][column])
+		if !ok {
+			continue
+		}
+		// the value the cell would need to have
+		corrected := f - difference
+		if i == totalRow {
+			corrected = f + difference
+		}
+		before := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+		after := strconv.FormatFloat(math.Abs(corrected), 'f', decimals, 64)
+		if oneDigitOff(before, after) {
+			cells = append(cells, Cell{
+				Row:        i,
+				Column:     column,
+				Text:       rows[i][column],
+				Suggestion: strconv.FormatFloat(corrected, 'f', decimals, 64),
+			})
+		}
+	}
+	return cells
+}
+
+// oneDigitOff is true if the numbers differ in a single digit, or by two adjacent swapped digits
+func oneDigitOff(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	diffs := make([]int, 0)
+	for k := range a {
+		if a[k] != b[k] {
+			diffs = append(diffs, k)
+		}
+	}
+	if len(diffs) == 1 {
+		return true
+	}
+	return len(diffs) == 2 && diffs[1] == diffs[0]+1 && a[diffs[0]] == b[diffs[1]] && a[diffs[1]] == b[diffs[0]]
+}
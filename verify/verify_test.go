@@ -0,0 +1,80 @@
+package verify
+
+import (
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		rows    [][]string
+		skip    []int
+		ok      bool
+	}{
+		{
+			name:    "total",
+			columns: []string{"Item", "Amount"},
+			rows: [][]string{
+				{"Rent", "1200"},
+				{"Food", "300"},
+				{"Total", "1500"},
+			},
+			ok: true,
+		},
+		{
+			name:    "wrong total",
+			columns: []string{"Item", "Amount"},
+			rows: [][]string{
+				{"Rent", "1200"},
+				{"Food", "300"},
+				{"Total", "1600"},
+			},
+			ok: false,
+		},
+		{
+			// the page numbers don't sum to the page of the total
+			name:    "page column",
+			columns: []string{"Page", "Item", "Amount"},
+			rows: [][]string{
+				{"1", "Rent", "1200"},
+				{"2", "Food", "300"},
+				{"2", "Total", "1500"},
+			},
+			skip: []int{0},
+			ok:   true,
+		},
+		{
+			name:    "level column",
+			columns: []string{"Item", "Amount", "Level"},
+			rows: [][]string{
+				{"Rent", "1200", "1"},
+				{"Food", "300", "1"},
+				{"Total", "1500", "0"},
+			},
+			skip: []int{2},
+			ok:   true,
+		},
+		{
+			name:    "line item that starts with total",
+			columns: []string{"Code", "Description", "Amount"},
+			rows: [][]string{
+				{"A1", "Total Care Plan", "100"},
+				{"A2", "Visits", "50"},
+				{"", "Total", "150"},
+			},
+			ok: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Verify(tt.columns, tt.rows, tt.skip)
+			if report.OK != tt.ok {
+				t.Errorf("got ok %t, want %t: %+v", report.OK, tt.ok, report.Checks)
+			}
+			if len(report.Checks) != 1 {
+				t.Errorf("got %d checks, want 1", len(report.Checks))
+			}
+		})
+	}
+}
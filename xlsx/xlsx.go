@@ -0,0 +1,268 @@
+package xlsx
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vegarsti/extract"
+	"github.com/vegarsti/extract/normalize"
+	"github.com/vegarsti/extract/schema"
+)
+
+// MediaType of Office Open XML workbooks, i.e. .xlsx files
+const MediaType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// Sheet is a table in a worksheet of the workbook
+type Sheet struct {
+	Name  string
+	Table *extract.Table
+}
+
+// styles of the cells, by their index in cellXfs in styles.xml
+const (
+	styleDefault = 0
+	styleHeader  = 1
+	stylePercent = 2
+)
+
+// FromTable returns a workbook with the table in a single sheet
+func FromTable(table *extract.Table, l normalize.Locale) ([]byte, error) {
+	return FromSheets([]Sheet{{Name: "Table", Table: table}}, l)
+}
+
+// ByPage splits the table into one sheet per page, with the header repeated on each
+func ByPage(table *extract.Table) []Sheet {
+	sheets := make([]Sheet, 0)
+	byPage := make(map[int]*extract.Table)
+	header := table.Rows
+	if table.HeaderRows <= len(table.Rows) {
+		header = table.Rows[:table.HeaderRows]
+	}
+	for _, row := range table.Rows[len(header):] {
+		page := 0
+		if len(row) > 0 {
+			page = row[0].Page
+		}
+		t, ok := byPage[page]
+		if !ok {
+			t = &extract.Table{
+				Rows:       append([][]extract.Cell{}, header...),
+				Columns:    table.Columns,
+				HeaderRows: table.HeaderRows,
+				Pages:      []int{page},
+				Direction:  table.Direction,
+				Locale:     table.Locale,
+			}
+			byPage[page] = t
+			sheets = append(sheets, Sheet{Name: fmt.Sprintf("Page %d", page), Table: t})
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	if len(sheets) == 0 {
+		return []Sheet{{Name: "Table", Table: table}}
+	}
+	return sheets
+}
+
+// FromSheets returns a workbook with a worksheet for each sheet, with a bold header that stays in place
+func FromSheets(sheets []Sheet, l normalize.Locale) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypes(len(sheets))},
+		{"_rels/.rels", rootRels},
+		{"xl/workbook.xml", workbook(sheets)},
+		{"xl/_rels/workbook.xml.rels", workbookRels(len(sheets))},
+		{"xl/styles.xml", styles},
+	}
+	for i, sheet := range sheets {
+		files = append(files, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), worksheet(sheet.Table, l)})
+	}
+	for _, file := range files {
+		f, err := w.Create(file.name)
+		if err != nil {
+			return nil, fmt.Errorf("create %s: %w", file.name, err)
+		}
+		if _, err := f.Write([]byte(file.content)); err != nil {
+			return nil, fmt.Errorf("write %s: %w", file.name, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("close zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// worksheet returns the XML of the sheet with the table
+func worksheet(table *extract.Table, l normalize.Locale) string {
+	lines := table.Flattened()
+	headerRows := 0
+	if table.HeaderRows > 0 && table.HeaderRows <= len(table.Rows) {
+		headerRows = 1
+	}
+
+	// numbers and dates are only recognized in normalized text
+	body := normalize.Body(table, l)
+	columns := schema.Infer(table.Columns, body)
+	values := schema.Values(columns, body)
+
+	s := &strings.Builder{}
+	s.WriteString(xml.Header)
+	s.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if headerRows > 0 {
+		s.WriteString(`<sheetViews><sheetView workbookViewId="0">`)
+		s.WriteString(`<pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/>`)
+		s.WriteString(`</sheetView></sheetViews>`)
+	}
+	s.WriteString(`<sheetData>`)
+	for i, line := range lines {
+		fmt.Fprintf(s, `<row r="%d">`, i+1)
+		for j, text := range line {
+			ref := cellReference(i, j)
+			if i < headerRows {
+				fmt.Fprintf(s, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, styleHeader, escape(text))
+				continue
+			}
+			var value interface{} = text
+			if row := values[i-headerRows]; j < len(row) {
+				value = row[j]
+			}
+			switch v := value.(type) {
+			case nil:
+				continue
+			case int64:
+				fmt.Fprintf(s, `<c r="%s"><v>%d</v></c>`, ref, v)
+			case float64:
+				if j < len(columns) && columns[j].Type == schema.Percent {
+					fmt.Fprintf(s, `<c r="%s" s="%d"><v>%s</v></c>`, ref, stylePercent, strconv.FormatFloat(v/100, 'g', -1, 64))
+				} else {
+					fmt.Fprintf(s, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(v, 'g', -1, 64))
+				}
+			case bool:
+				b := 0
+				if v {
+					b = 1
+				}
+				fmt.Fprintf(s, `<c r="%s" t="b"><v>%d</v></c>`, ref, b)
+			default:
+				// text, and dates which Excel would otherwise read in the locale of the reader
+				fmt.Fprintf(s, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, styleDefault, escape(fmt.Sprint(v)))
+			}
+		}
+		s.WriteString(`</row>`)
+	}
+	s.WriteString(`</sheetData></worksheet>`)
+	return s.String()
+}
+
+// cellReference returns the reference to the cell in row i and column j, e.g. "B3"
+func cellReference(i, j int) string {
+	column := ""
+	for j >= 0 {
+		column = string(rune('A'+j%26)) + column
+		j = j/26 - 1
+	}
+	return column + strconv.Itoa(i+1)
+}
+
+func escape(s string) string {
+	b := &strings.Builder{}
+	xml.EscapeText(b, []byte(s))
+	return b.String()
+}
+
+// sheetName makes the name valid in Excel: at most 31 characters, without []:*?/\
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return ' '
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if strings.TrimSpace(name) == "" {
+		name = "Sheet"
+	}
+	return name
+}
+
+func workbook(sheets []Sheet) string {
+	s := &strings.Builder{}
+	s.WriteString(xml.Header)
+	s.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	used := make(map[string]bool)
+	for i, sheet := range sheets {
+		// names must be unique, regardless of case
+		base := sheetName(sheet.Name)
+		name := base
+		for n := 2; used[strings.ToLower(name)]; n++ {
+			suffix := fmt.Sprintf(" (%d)", n)
+			runes := []rune(base)
+			if len(runes) > 31-len(suffix) {
+				runes = runes[:31-len(suffix)]
+			}
+			name = string(runes) + suffix
+		}
+		used[strings.ToLower(name)] = true
+		fmt.Fprintf(s, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+	}
+	s.WriteString(`</sheets></workbook>`)
+	return s.String()
+}
+
+func workbookRels(n int) string {
+	s := &strings.Builder{}
+	s.WriteString(xml.Header)
+	s.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(s, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(s, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, n+1)
+	s.WriteString(`</Relationships>`)
+	return s.String()
+}
+
+func contentTypes(n int) string {
+	s := &strings.Builder{}
+	s.WriteString(xml.Header)
+	s.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	s.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	s.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	s.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	s.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(s, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	s.WriteString(`</Types>`)
+	return s.String()
+}
+
+var rootRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// styles has the default style, bold for the header, and percent with two decimals (built-in format 10)
+var styles = xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`